@@ -0,0 +1,37 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// handleReset zeroes the request counters and the recent-request buffer and
+// forgets all tenants, responding with the stats as they were just before
+// the reset. Prometheus counters are left alone since scrapers expect them
+// to be monotonic.
+func (a *app) handleReset(w http.ResponseWriter, r *http.Request) {
+	if !checkToken(r, a.cfg.adminToken) {
+		slog.Warn("admin auth failed", "path", r.URL.Path, "remote", r.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJSONError(w, http.StatusUnauthorized, "invalid admin token")
+		return
+	}
+
+	snap := a.stats.reset()
+	a.recent.reset()
+	a.tenants.reset()
+	slog.Info("stats reset", "remote", r.RemoteAddr)
+	writeJSON(w, http.StatusOK, snap)
+}
+
+// checkToken reports whether r carries "Authorization: Bearer <token>". An
+// empty token disables the check.
+func checkToken(r *http.Request, token string) bool {
+	if token == "" {
+		return true
+	}
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strconv"
+	"sync/atomic"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// attrTracker tallies the distinct values of selected resource attributes.
+// At most limit distinct values are kept per key.
+type attrTracker struct {
+	values  map[string]*tally
+	missing map[string]*atomic.Int64
+}
+
+func newAttrTracker(keys []string, limit int) *attrTracker {
+	t := &attrTracker{
+		values:  make(map[string]*tally, len(keys)),
+		missing: make(map[string]*atomic.Int64, len(keys)),
+	}
+	for _, k := range keys {
+		t.values[k] = newTally(limit)
+		t.missing[k] = new(atomic.Int64)
+	}
+	return t
+}
+
+// observe records the tracked attributes of every resource in msg.
+func (t *attrTracker) observe(msg proto.Message) {
+	for _, res := range exportResources(msg) {
+		for key, values := range t.values {
+			if v, ok := resourceAttr(res, key); ok {
+				values.add(v)
+			} else {
+				t.missing[key].Add(1)
+			}
+		}
+	}
+}
+
+type attrSnapshot struct {
+	tallySnapshot
+	Missing int64 `json:"missing"`
+}
+
+// load returns the tallies per key, clearing them when reset is set.
+func (t *attrTracker) load(reset bool) map[string]attrSnapshot {
+	snap := make(map[string]attrSnapshot, len(t.values))
+	for key, values := range t.values {
+		snap[key] = attrSnapshot{
+			tallySnapshot: values.snapshot(reset),
+			Missing:       loadCounter(t.missing[key], reset),
+		}
+	}
+	return snap
+}
+
+// exportResources returns the resources of a decoded export request.
+func exportResources(msg proto.Message) []*resourcepb.Resource {
+	var res []*resourcepb.Resource
+	switch req := msg.(type) {
+	case *coltracepb.ExportTraceServiceRequest:
+		for _, rs := range req.GetResourceSpans() {
+			res = append(res, rs.GetResource())
+		}
+	case *collogspb.ExportLogsServiceRequest:
+		for _, rl := range req.GetResourceLogs() {
+			res = append(res, rl.GetResource())
+		}
+	case *colmetricspb.ExportMetricsServiceRequest:
+		for _, rm := range req.GetResourceMetrics() {
+			res = append(res, rm.GetResource())
+		}
+	}
+	return res
+}
+
+// resourceAttr returns the value of the attribute key on res as a string.
+func resourceAttr(res *resourcepb.Resource, key string) (string, bool) {
+	for _, kv := range res.GetAttributes() {
+		if kv.GetKey() == key {
+			return anyValueString(kv.GetValue()), true
+		}
+	}
+	return "", false
+}
+
+func anyValueString(v *commonpb.AnyValue) string {
+	switch x := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return x.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return strconv.FormatBool(x.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		return strconv.FormatInt(x.IntValue, 10)
+	case *commonpb.AnyValue_DoubleValue:
+		return strconv.FormatFloat(x.DoubleValue, 'g', -1, 64)
+	}
+	return v.String()
+}
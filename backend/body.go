@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+// errBodyTooLarge is returned by readBody when the body exceeds the limit.
+var errBodyTooLarge = errors.New("request body too large")
+
+// readBody drains the request body and decompresses it. It returns the
+// decoded body together with the raw bytes received on the wire; raw is
+// returned even when decompression fails. If the body could not be received
+// in full, err is a *truncatedBodyError and raw only holds its prefix. When
+// maxBytes is positive, both the raw and the decompressed body are limited to
+// that many bytes.
+func readBody(w http.ResponseWriter, r *http.Request, maxBytes int64) (body, raw []byte, err error) {
+	defer func() { _ = r.Body.Close() }()
+
+	src := r.Body
+	if maxBytes > 0 {
+		src = http.MaxBytesReader(w, r.Body, maxBytes)
+	}
+	raw, err = io.ReadAll(src)
+	if err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			err = errBodyTooLarge
+		}
+		return nil, raw, &truncatedBodyError{err: err}
+	}
+	enc := contentEncoding(r)
+	if enc == "" {
+		return raw, raw, nil
+	}
+
+	zr, err := newDecompressor(enc, bytes.NewReader(raw))
+	if err != nil {
+		return nil, raw, err
+	}
+	defer func() { _ = zr.Close() }()
+
+	var dst io.Reader = zr
+	if maxBytes > 0 {
+		dst = io.LimitReader(zr, maxBytes+1)
+	}
+	body, err = io.ReadAll(dst)
+	if err != nil {
+		return nil, raw, fmt.Errorf("invalid %s body: %w", enc, err)
+	}
+	if maxBytes > 0 && int64(len(body)) > maxBytes {
+		return nil, raw, errBodyTooLarge
+	}
+	return body, raw, nil
+}
+
+// contentEncoding returns the request's Content-Encoding, or "" when the body
+// is not compressed.
+func contentEncoding(r *http.Request) string {
+	enc := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if enc == "identity" {
+		return ""
+	}
+	return enc
+}
+
+// truncatedBodyError is returned by readBody when reading the body off the
+// wire failed part way through.
+type truncatedBodyError struct {
+	err error
+}
+
+func (e *truncatedBodyError) Error() string { return e.err.Error() }
+func (e *truncatedBodyError) Unwrap() error { return e.err }
+
+// unsupportedEncodingError is returned by readBody for a Content-Encoding it
+// cannot decompress.
+type unsupportedEncodingError struct {
+	encoding string
+}
+
+func (e *unsupportedEncodingError) Error() string {
+	return fmt.Sprintf("unsupported content encoding %q", e.encoding)
+}
+
+// newDecompressor returns a reader decompressing src according to enc.
+func newDecompressor(enc string, src *bytes.Reader) (io.ReadCloser, error) {
+	switch enc {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(src)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip body: %w", err)
+		}
+		return zr, nil
+	case "deflate":
+		// HTTP deflate is meant to be zlib-wrapped, but many clients send a
+		// raw DEFLATE stream, so accept both.
+		if isZlibHeader(src) {
+			zr, err := zlib.NewReader(src)
+			if err != nil {
+				return nil, fmt.Errorf("invalid deflate body: %w", err)
+			}
+			return zr, nil
+		}
+		return flate.NewReader(src), nil
+	case "br":
+		return io.NopCloser(brotli.NewReader(src)), nil
+	}
+	return nil, &unsupportedEncodingError{encoding: enc}
+}
+
+// isZlibHeader reports whether src starts with a zlib stream header without
+// consuming any input.
+func isZlibHeader(src *bytes.Reader) bool {
+	var hdr [2]byte
+	n, _ := src.ReadAt(hdr[:], 0)
+	return n == 2 && hdr[0]&0x0f == 8 && (uint16(hdr[0])<<8|uint16(hdr[1]))%31 == 0
+}
+
+// drainBody discards up to maxBytes of the request body so the connection can
+// be reused after an early error response.
+func drainBody(r *http.Request, maxBytes int64) {
+	src := io.Reader(r.Body)
+	if maxBytes > 0 {
+		src = io.LimitReader(r.Body, maxBytes)
+	}
+	_, _ = io.Copy(io.Discard, src)
+	_ = r.Body.Close()
+}
+
+// errBodyDeadline is returned by a deadlineBody once its deadline has passed.
+var errBodyDeadline = errors.New("request body deadline exceeded")
+
+// deadlineBody fails reads of a request body once the deadline of ctx has
+// passed. A read still blocked on the connection at that point is
+// interrupted by moving the connection's read deadline to now; closing the
+// body then marks the response Connection: close, since the connection must
+// not be reused.
+type deadlineBody struct {
+	io.ReadCloser
+	ctx    context.Context
+	w      http.ResponseWriter
+	stop   func() bool
+	closed bool
+}
+
+func newDeadlineBody(ctx context.Context, w http.ResponseWriter, body io.ReadCloser) *deadlineBody {
+	rc := http.NewResponseController(w)
+	return &deadlineBody{
+		ReadCloser: body,
+		ctx:        ctx,
+		w:          w,
+		stop:       context.AfterFunc(ctx, func() { _ = rc.SetReadDeadline(time.Now()) }),
+	}
+}
+
+func (d *deadlineBody) Read(p []byte) (int, error) {
+	if d.expired() {
+		return 0, errBodyDeadline
+	}
+	n, err := d.ReadCloser.Read(p)
+	if err != nil && d.expired() {
+		err = errBodyDeadline
+	}
+	return n, err
+}
+
+func (d *deadlineBody) expired() bool {
+	return errors.Is(d.ctx.Err(), context.DeadlineExceeded)
+}
+
+// Close stops watching the deadline. Handlers close the body before writing
+// their response, so the Connection header still takes effect.
+func (d *deadlineBody) Close() error {
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+	if !d.stop() {
+		d.w.Header().Set("Connection", "close")
+	}
+	return d.ReadCloser.Close()
+}
+
+// throttledBody limits the rate at which a request body is read.
+type throttledBody struct {
+	io.ReadCloser
+	ctx   context.Context
+	rate  int64 // bytes per second
+	start time.Time
+	read  int64
+}
+
+// throttleBody wraps body so it is read at no more than rate bytes per
+// second. Reads return at most a tenth of a second's worth of data, so short
+// bodies finish promptly, and waiting stops when ctx is done.
+func throttleBody(ctx context.Context, body io.ReadCloser, rate int64) io.ReadCloser {
+	return &throttledBody{ReadCloser: body, ctx: ctx, rate: rate, start: time.Now()}
+}
+
+func (t *throttledBody) Read(p []byte) (int, error) {
+	if chunk := max(t.rate/10, 1); int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+	n, err := t.ReadCloser.Read(p)
+	t.read += int64(n)
+
+	due := t.start.Add(time.Duration(float64(t.read) / float64(t.rate) * float64(time.Second)))
+	if wait := time.Until(due); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-t.ctx.Done():
+			return n, t.ctx.Err()
+		}
+	}
+	return n, err
+}
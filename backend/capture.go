@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// captureSink appends raw request bodies to a single file as length-prefixed
+// records, rotating to a new file once the current one exceeds maxSize.
+// Files are named captures-0001.bin, captures-0002.bin, and so on.
+//
+// Each record is a big-endian uint32 length followed by that many bytes:
+// the receive time as a big-endian int64 of Unix nanoseconds, the signal as
+// one byte (0 traces, 1 logs, 2 metrics), the content type and the content
+// encoding each as a one-byte length and the string, and the raw body.
+type captureSink struct {
+	dir     string
+	maxSize int64
+
+	mu   sync.Mutex
+	seq  int
+	f    *os.File
+	w    *bufio.Writer
+	size int64
+}
+
+const captureBufferSize = 1 << 20
+
+func newCaptureSink(dir string, maxSize int64) (*captureSink, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &captureSink{dir: dir, maxSize: maxSize, seq: lastCaptureSeq(dir)}
+	if err := s.rotate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// lastCaptureSeq returns the highest sequence number among the capture files
+// in dir, so a restart continues numbering instead of overwriting.
+func lastCaptureSeq(dir string) int {
+	names, _ := filepath.Glob(filepath.Join(dir, "captures-*.bin"))
+	var last int
+	for _, name := range names {
+		n := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(name), "captures-"), ".bin")
+		if seq, err := strconv.Atoi(n); err == nil {
+			last = max(last, seq)
+		}
+	}
+	return last
+}
+
+// rotate closes the current file, if any, and opens the next one. The
+// caller must hold mu or have exclusive access.
+func (s *captureSink) rotate() error {
+	if s.f != nil {
+		if err := s.closeFile(); err != nil {
+			return err
+		}
+	}
+	s.seq++
+	f, err := os.OpenFile(filepath.Join(s.dir, fmt.Sprintf("captures-%04d.bin", s.seq)),
+		os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	s.f, s.w, s.size = f, bufio.NewWriterSize(f, captureBufferSize), 0
+	return nil
+}
+
+func (s *captureSink) closeFile() error {
+	err := s.w.Flush()
+	if cerr := s.f.Close(); err == nil {
+		err = cerr
+	}
+	s.f, s.w = nil, nil
+	return err
+}
+
+// write appends a record for raw. Content types and encodings longer than
+// 255 bytes are truncated.
+func (s *captureSink) write(sig otlpSignal, contentType, contentEncoding string, raw []byte) error {
+	contentType = contentType[:min(len(contentType), 255)]
+	contentEncoding = contentEncoding[:min(len(contentEncoding), 255)]
+	n := 8 + 1 + 1 + len(contentType) + 1 + len(contentEncoding) + len(raw)
+
+	hdr := make([]byte, 0, 4+8+3+len(contentType)+len(contentEncoding))
+	hdr = binary.BigEndian.AppendUint32(hdr, uint32(n))
+	hdr = binary.BigEndian.AppendUint64(hdr, uint64(time.Now().UnixNano()))
+	hdr = append(hdr, byte(sig), byte(len(contentType)))
+	hdr = append(hdr, contentType...)
+	hdr = append(hdr, byte(len(contentEncoding)))
+	hdr = append(hdr, contentEncoding...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.w == nil {
+		return os.ErrClosed
+	}
+	if s.size > 0 && s.size+int64(4+n) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	if _, err := s.w.Write(hdr); err != nil {
+		return err
+	}
+	if _, err := s.w.Write(raw); err != nil {
+		return err
+	}
+	s.size += int64(4 + n)
+	return nil
+}
+
+// flushLoop flushes buffered records every interval until ctx is done.
+func (s *captureSink) flushLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			if s.w != nil {
+				if err := s.w.Flush(); err != nil {
+					slog.Warn("capture flush failed", "error", err)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// close flushes and closes the current file.
+func (s *captureSink) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	return s.closeFile()
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// config holds the settings resolved from command-line flags and the
+// environment.
+type config struct {
+	mode            string
+	addr            string
+	grpcAddr        string
+	unixSocket      string
+	pathPrefix      string
+	tlsCert         string
+	tlsKey          string
+	shutdownTimeout time.Duration
+
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	readHeaderTimeout time.Duration
+
+	latency          durationRange
+	errorRate        float64
+	dropRate         float64
+	retryAfter       time.Duration
+	statusDist       statusDist
+	partialReject    int64
+	partialMessage   string
+	cors             corsPolicy
+	reloadConfig     string
+	dumpDir          string
+	captureDir       string
+	captureMaxSize   byteSize
+	captureFlush     time.Duration
+	maxBody          byteSize
+	readThrottle     byteSize
+	bodyDeadline     time.Duration
+	gzipMinSize      byteSize
+	responseSize     sizeRange
+	holdDuration     time.Duration
+	holdMax          byteSize
+	contentTypes     map[string]bool
+	strict           bool
+	requireUserAgent bool
+	logFormat        string
+	logLevel         slog.Level
+	pprof            bool
+	verbose          bool
+	adminToken       string
+	authToken        string
+	rate             float64
+	burst            int
+	maxConcurrent    int
+	recentSize       int
+	maxTenants       int
+	reportInterval   time.Duration
+	fastRequestIDs   bool
+	h2c              bool
+	ws               bool
+	dedupWindow      time.Duration
+	dedupSize        int
+	trackAttrs       []string
+	trackLimit       int
+	endpoints        map[otlpSignal]*endpointConfig
+
+	// Load generator settings, used with -mode=loadgen.
+	target          string
+	concurrency     int
+	sendRate        float64
+	duration        time.Duration
+	spansPerRequest int
+
+	// replayDir holds the captures re-sent with -mode=replay, which also uses
+	// -target, -concurrency, and -send-rate.
+	replayDir string
+}
+
+// endpointConfig holds the settings that can differ between the traces,
+// logs, and metrics endpoints.
+type endpointConfig struct {
+	responseFile        string
+	responseContentType string
+
+	// status, when not 200, is sent for every request instead of the normal
+	// response.
+	status int
+
+	// latency and errorRate override the global -latency and -error-rate
+	// for this endpoint when set.
+	latency   *durationRange
+	errorRate *float64
+
+	// response is the success body loaded from responseFile, or nil to send
+	// the default "{}".
+	response []byte
+}
+
+func parseConfig() *config {
+	cfg := &config{
+		maxBody:        4 << 20,
+		gzipMinSize:    1 << 10,
+		holdMax:        256 << 20,
+		captureMaxSize: 256 << 20,
+		endpoints:      make(map[otlpSignal]*endpointConfig),
+	}
+	flag.StringVar(&cfg.mode, "mode", "server", "what to run: server, loadgen, or replay")
+	flag.StringVar(&cfg.addr, "addr", envOr("OTLP_MOCK_ADDR", ":14318"),
+		"listen address; overrides $OTLP_MOCK_ADDR")
+	flag.StringVar(&cfg.unixSocket, "unix", "",
+		"listen on this Unix domain socket path instead of the -addr TCP address; loadgen and replay send over it")
+	flag.StringVar(&cfg.grpcAddr, "grpc-addr", "",
+		"listen address for the OTLP/gRPC server; empty disables it")
+	flag.StringVar(&cfg.pathPrefix, "path-prefix", "",
+		"prefix for the OTLP endpoint paths, e.g. /otlp for /otlp/v1/traces")
+	flag.StringVar(&cfg.tlsCert, "tls-cert", "", "TLS certificate file; enables HTTPS together with -tls-key")
+	flag.StringVar(&cfg.tlsKey, "tls-key", "", "TLS private key file; enables HTTPS together with -tls-cert")
+	flag.DurationVar(&cfg.shutdownTimeout, "shutdown-timeout", 10*time.Second,
+		"how long to wait for in-flight requests on shutdown")
+	flag.DurationVar(&cfg.readTimeout, "read-timeout", 30*time.Second,
+		"maximum duration for reading an entire request, including the body")
+	flag.DurationVar(&cfg.writeTimeout, "write-timeout", 30*time.Second,
+		"maximum duration before timing out writes of the response")
+	flag.DurationVar(&cfg.idleTimeout, "idle-timeout", 120*time.Second,
+		"how long to keep idle keep-alive connections open")
+	flag.DurationVar(&cfg.readHeaderTimeout, "read-header-timeout", 5*time.Second,
+		"maximum duration for reading request headers")
+	flag.Var(&cfg.latency, "latency",
+		"artificial response delay, fixed (250ms) or a jitter range (100ms-400ms)")
+	flag.Float64Var(&cfg.errorRate, "error-rate", 0,
+		"fraction of requests (0.0-1.0) answered with 503 Service Unavailable")
+	flag.Float64Var(&cfg.dropRate, "drop-rate", 0,
+		"fraction of requests (0.0-1.0) whose connection is reset after reading the body")
+	flag.Var(&cfg.statusDist, "status-dist",
+		"weighted response statuses in percent, e.g. 200:90,429:5,503:3,500:2")
+	flag.DurationVar(&cfg.retryAfter, "retry-after", time.Second,
+		"Retry-After value sent with injected error responses")
+	flag.Int64Var(&cfg.partialReject, "partial-reject", 0,
+		"report this many items per request as rejected via an OTLP partial success")
+	flag.StringVar(&cfg.partialMessage, "partial-reject-message", "rejected by mock collector",
+		"error_message sent with partial success responses")
+	flag.StringVar(&cfg.reloadConfig, "reload-config", "",
+		"file with latency, error-rate, and drop-rate settings re-read on SIGHUP, one name=value per line")
+	flag.StringVar(&cfg.dumpDir, "dump-dir", "",
+		"directory to write each raw request body to; empty disables dumping")
+	flag.StringVar(&cfg.captureDir, "capture-dir", "",
+		"directory to append raw request bodies to as length-prefixed records in rotating captures-NNNN.bin files")
+	flag.Var(&cfg.captureMaxSize, "capture-max-size",
+		"rotate to a new -capture-dir file once the current one reaches this size")
+	flag.DurationVar(&cfg.captureFlush, "capture-flush", time.Second,
+		"how often buffered -capture-dir records are flushed to disk")
+	flag.Var(&cfg.maxBody, "max-body",
+		"maximum request body size, raw or decompressed (e.g. 512KiB, 4MiB); 0 disables the limit")
+	flag.Var(&cfg.readThrottle, "read-throttle",
+		"read request bodies at most this many bytes per second (e.g. 16KiB); 0 disables throttling")
+	flag.Var(&cfg.responseSize, "response-size",
+		"pad success responses to this size, fixed (4KiB) or a range (1KiB-64KiB); 0 sends {}")
+	flag.Var(&cfg.gzipMinSize, "gzip-min-size",
+		"gzip response bodies of at least this size when the client accepts it; 0 disables compression")
+	flag.DurationVar(&cfg.holdDuration, "hold-duration", 0,
+		"keep each request body in memory this long after handling it; 0 releases bodies immediately")
+	flag.Var(&cfg.holdMax, "hold-max",
+		"maximum total size of bodies held by -hold-duration; requests beyond it get 503")
+	flag.DurationVar(&cfg.bodyDeadline, "body-deadline", 0,
+		"answer 408 and close the connection when a request body takes longer than this to arrive; 0 disables it")
+	contentTypes := flag.String("content-types", contentTypeProtobuf+","+contentTypeJSON,
+		"comma-separated list of accepted request content types")
+	flag.BoolVar(&cfg.strict, "strict", false,
+		"reject undecodable or malformed OTLP payloads with 400 instead of accepting them")
+	flag.BoolVar(&cfg.requireUserAgent, "require-user-agent", false,
+		"reject OTLP requests without a User-Agent header with 400")
+	flag.StringVar(&cfg.logFormat, "log-format", "text", "log output format: text or json")
+	flag.TextVar(&cfg.logLevel, "log-level", slog.LevelInfo, "minimum log level: debug, info, warn, or error")
+	flag.BoolVar(&cfg.verbose, "verbose", false,
+		"log connection state changes and the protocol, TLS, reuse, and headers of every request")
+	flag.BoolVar(&cfg.pprof, "pprof", false, "mount net/http/pprof handlers under /debug/pprof/")
+	flag.StringVar(&cfg.authToken, "auth-token", "",
+		"bearer token required by the OTLP endpoints, and sent by loadgen; empty disables authentication")
+	flag.StringVar(&cfg.adminToken, "admin-token", "",
+		"bearer token required by /admin endpoints; empty disables the check")
+	flag.Float64Var(&cfg.rate, "rate", 0, "per-client rate limit in requests per second; 0 disables rate limiting")
+	flag.IntVar(&cfg.burst, "burst", 10, "per-client rate limit burst size")
+	flag.IntVar(&cfg.maxConcurrent, "max-concurrent", 0,
+		"maximum concurrently handled OTLP requests; excess requests get 503; 0 means unlimited")
+	flag.IntVar(&cfg.recentSize, "recent-size", 1000, "number of recent requests kept for /recent")
+	flag.IntVar(&cfg.maxTenants, "max-tenants", 100,
+		"maximum number of X-Tenant values tracked separately; further tenants share the "+overflowTenant+" bucket")
+	flag.DurationVar(&cfg.reportInterval, "report-interval", 0,
+		"how often to log aggregate throughput; 0 disables periodic reports")
+	flag.BoolVar(&cfg.fastRequestIDs, "fast-request-ids", false,
+		"generate missing X-Request-ID values with math/rand instead of crypto/rand")
+	flag.BoolVar(&cfg.h2c, "h2c", false, "serve HTTP/2 over cleartext (h2c) in addition to HTTP/1.1")
+	flag.DurationVar(&cfg.dedupWindow, "dedup-window", 0,
+		"flag request bodies received again within this window as duplicates; 0 disables detection")
+	flag.IntVar(&cfg.dedupSize, "dedup-size", 100000,
+		"maximum number of body hashes remembered for duplicate detection")
+	trackAttrs := flag.String("track-attrs", "",
+		"comma-separated resource attribute keys whose distinct values are tallied in /stats")
+	flag.IntVar(&cfg.trackLimit, "track-attrs-limit", 1000,
+		"maximum number of distinct values tracked per -track-attrs key")
+	flag.BoolVar(&cfg.ws, "ws", false,
+		"experimental: accept OTLP/protobuf trace exports as WebSocket binary messages on /v1/traces/ws")
+	corsOrigins := flag.String("cors-origins", "",
+		"comma-separated list of allowed CORS origins; empty allows any origin")
+	flag.StringVar(&cfg.target, "target", "", "loadgen: OTLP/HTTP endpoint or base URL to send exports to")
+	flag.IntVar(&cfg.concurrency, "concurrency", 4, "loadgen: number of concurrent senders")
+	flag.Float64Var(&cfg.sendRate, "send-rate", 0, "loadgen: total exports per second; 0 sends as fast as possible")
+	flag.DurationVar(&cfg.duration, "duration", 10*time.Second, "loadgen: how long to run; 0 runs until interrupted")
+	flag.IntVar(&cfg.spansPerRequest, "spans", 10, "loadgen: spans per export request")
+	flag.StringVar(&cfg.replayDir, "replay-dir", "", "replay: directory of payloads captured with -dump-dir")
+	for _, sig := range allSignals {
+		ec := new(endpointConfig)
+		cfg.endpoints[sig] = ec
+		flag.StringVar(&ec.responseFile, sig.String()+"-response", "",
+			"file whose contents are returned on success by the "+sig.String()+" endpoint instead of {}")
+		flag.StringVar(&ec.responseContentType, sig.String()+"-response-content-type", contentTypeJSON,
+			"Content-Type of the -"+sig.String()+"-response body")
+		flag.IntVar(&ec.status, sig.String()+"-status", http.StatusOK,
+			"HTTP status forced for every request to the "+sig.String()+" endpoint")
+		flag.Func(sig.String()+"-latency", "override -latency for the "+sig.String()+" endpoint", func(s string) error {
+			ec.latency = new(durationRange)
+			return ec.latency.Set(s)
+		})
+		flag.Func(sig.String()+"-error-rate", "override -error-rate for the "+sig.String()+" endpoint", func(s string) error {
+			f, err := strconv.ParseFloat(s, 64)
+			ec.errorRate = &f
+			return err
+		})
+	}
+	flag.Parse()
+
+	if cfg.pathPrefix = strings.Trim(cfg.pathPrefix, "/"); cfg.pathPrefix != "" {
+		cfg.pathPrefix = "/" + cfg.pathPrefix
+	}
+	cfg.cors = parseCORSOrigins(*corsOrigins)
+	cfg.contentTypes = make(map[string]bool)
+	for _, ct := range strings.Split(*contentTypes, ",") {
+		if ct = strings.TrimSpace(ct); ct != "" {
+			cfg.contentTypes[ct] = true
+		}
+	}
+	for _, key := range strings.Split(*trackAttrs, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			cfg.trackAttrs = append(cfg.trackAttrs, key)
+		}
+	}
+
+	if err := cfg.validate(); err != nil {
+		exitConfigError(err)
+	}
+	for _, ec := range cfg.endpoints {
+		if ec.responseFile == "" {
+			continue
+		}
+		b, err := os.ReadFile(ec.responseFile)
+		if err != nil {
+			exitConfigError(err)
+		}
+		ec.response = b
+	}
+
+	if err := setupLogger(cfg.logFormat, cfg.logLevel); err != nil {
+		exitConfigError(err)
+	}
+	return cfg
+}
+
+// exitConfigError reports an invalid configuration and exits with the same
+// status the flag package uses for bad arguments.
+func exitConfigError(err error) {
+	fmt.Fprintf(flag.CommandLine.Output(), "invalid configuration: %v\n", err)
+	os.Exit(2)
+}
+
+func (cfg *config) validate() error {
+	switch cfg.mode {
+	case "server":
+	case "loadgen":
+		if cfg.concurrency < 1 {
+			return fmt.Errorf("-concurrency must be at least 1, got %d", cfg.concurrency)
+		}
+		if cfg.spansPerRequest < 1 {
+			return fmt.Errorf("-spans must be at least 1, got %d", cfg.spansPerRequest)
+		}
+	case "replay":
+		if cfg.replayDir == "" {
+			return fmt.Errorf("-replay-dir is required with -mode=replay")
+		}
+		if cfg.concurrency < 1 {
+			return fmt.Errorf("-concurrency must be at least 1, got %d", cfg.concurrency)
+		}
+	default:
+		return fmt.Errorf("unknown -mode %q", cfg.mode)
+	}
+	if (cfg.tlsCert == "") != (cfg.tlsKey == "") {
+		return fmt.Errorf("-tls-cert and -tls-key must be set together")
+	}
+	if err := cfg.faultSettings().validate(); err != nil {
+		return err
+	}
+	if cfg.retryAfter < 0 {
+		return fmt.Errorf("-retry-after must not be negative, got %s", cfg.retryAfter)
+	}
+	if cfg.h2c && cfg.tlsEnabled() {
+		return fmt.Errorf("-h2c cannot be combined with TLS; HTTPS already negotiates HTTP/2")
+	}
+	if cfg.maxConcurrent < 0 {
+		return fmt.Errorf("-max-concurrent must not be negative, got %d", cfg.maxConcurrent)
+	}
+	if cfg.reportInterval < 0 {
+		return fmt.Errorf("-report-interval must not be negative, got %s", cfg.reportInterval)
+	}
+	if cfg.recentSize < 1 {
+		return fmt.Errorf("-recent-size must be at least 1, got %d", cfg.recentSize)
+	}
+	if cfg.maxTenants < 1 {
+		return fmt.Errorf("-max-tenants must be at least 1, got %d", cfg.maxTenants)
+	}
+	if cfg.rate < 0 {
+		return fmt.Errorf("-rate must not be negative, got %g", cfg.rate)
+	}
+	if cfg.captureDir != "" && cfg.captureMaxSize < 1<<10 {
+		return fmt.Errorf("-capture-max-size must be at least 1KiB, got %s", cfg.captureMaxSize)
+	}
+	if cfg.captureDir != "" && cfg.captureFlush <= 0 {
+		return fmt.Errorf("-capture-flush must be positive, got %s", cfg.captureFlush)
+	}
+	if cfg.bodyDeadline < 0 {
+		return fmt.Errorf("-body-deadline must not be negative, got %s", cfg.bodyDeadline)
+	}
+	if cfg.holdDuration < 0 {
+		return fmt.Errorf("-hold-duration must not be negative, got %s", cfg.holdDuration)
+	}
+	if cfg.holdDuration > 0 && cfg.holdMax <= 0 {
+		return fmt.Errorf("-hold-max must be positive when -hold-duration is set, got %s", cfg.holdMax)
+	}
+	if cfg.dedupWindow < 0 {
+		return fmt.Errorf("-dedup-window must not be negative, got %s", cfg.dedupWindow)
+	}
+	if cfg.dedupWindow > 0 && cfg.dedupSize < 1 {
+		return fmt.Errorf("-dedup-size must be at least 1, got %d", cfg.dedupSize)
+	}
+	if len(cfg.trackAttrs) > 0 && cfg.trackLimit < 1 {
+		return fmt.Errorf("-track-attrs-limit must be at least 1, got %d", cfg.trackLimit)
+	}
+	if cfg.rate > 0 && cfg.burst < 1 {
+		return fmt.Errorf("-burst must be at least 1, got %d", cfg.burst)
+	}
+	for _, sig := range allSignals {
+		ec := cfg.endpoints[sig]
+		if ec.status < 200 || ec.status > 599 {
+			return fmt.Errorf("-%s-status must be between 200 and 599, got %d", sig, ec.status)
+		}
+		if ec.errorRate != nil && (*ec.errorRate < 0 || *ec.errorRate > 1) {
+			return fmt.Errorf("-%s-error-rate must be between 0 and 1, got %g", sig, *ec.errorRate)
+		}
+	}
+	if cfg.partialReject < 0 {
+		return fmt.Errorf("-partial-reject must not be negative, got %d", cfg.partialReject)
+	}
+	return nil
+}
+
+// faultSettings returns the fault injection settings given on the command
+// line.
+func (cfg *config) faultSettings() *faultSettings {
+	return &faultSettings{latency: cfg.latency, errorRate: cfg.errorRate, dropRate: cfg.dropRate}
+}
+
+// faults returns the fault settings in effect for the endpoint: global with
+// the endpoint's own overrides applied.
+func (ec *endpointConfig) faults(global *faultSettings) *faultSettings {
+	if ec.latency == nil && ec.errorRate == nil {
+		return global
+	}
+	f := *global
+	if ec.latency != nil {
+		f.latency = *ec.latency
+	}
+	if ec.errorRate != nil {
+		f.errorRate = *ec.errorRate
+	}
+	return &f
+}
+
+// tlsEnabled reports whether the HTTP server should serve HTTPS.
+func (cfg *config) tlsEnabled() bool {
+	return cfg.tlsCert != "" && cfg.tlsKey != ""
+}
+
+// envOr returns the value of the environment variable key, or def when it is
+// unset or empty.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// corsPolicy decides which origins may call the OTLP endpoints. An empty
+// policy allows any origin without credentials.
+type corsPolicy struct {
+	origins map[string]bool
+}
+
+// parseCORSOrigins parses a comma-separated origin allowlist.
+func parseCORSOrigins(s string) corsPolicy {
+	p := corsPolicy{origins: make(map[string]bool)}
+	for _, origin := range strings.Split(s, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			p.origins[origin] = true
+		}
+	}
+	return p
+}
+
+// allowsOrigin reports whether origin may connect. Requests without an
+// Origin header come from non-browser clients and are always allowed.
+func (p corsPolicy) allowsOrigin(origin string) bool {
+	return origin == "" || len(p.origins) == 0 || p.origins[origin]
+}
+
+// setHeaders writes the CORS response headers for r. Timing-Allow-Origin is
+// set alongside so the Resource Timing API exposes Server-Timing to the page.
+func (p corsPolicy) setHeaders(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	if len(p.origins) == 0 {
+		h.Set("Access-Control-Allow-Origin", "*")
+		h.Set("Timing-Allow-Origin", "*")
+	} else {
+		h.Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		if !p.origins[origin] {
+			return
+		}
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Allow-Credentials", "true")
+		h.Set("Timing-Allow-Origin", origin)
+	}
+	h.Set("Access-Control-Allow-Methods", "POST, HEAD, OPTIONS")
+	// Browsers ignore the "*" wildcard on credentialed requests, so echo the
+	// requested headers back instead.
+	if reqHeaders := r.Header.Get("Access-Control-Request-Headers"); len(p.origins) > 0 && reqHeaders != "" {
+		h.Set("Access-Control-Allow-Headers", reqHeaders)
+	} else {
+		h.Set("Access-Control-Allow-Headers", "*")
+	}
+	h.Set("Access-Control-Max-Age", "86400")
+	h.Set("Access-Control-Expose-Headers", "Server-Timing, "+requestIDHeader)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// describe returns the resolved server settings for /debug/config. Secrets
+// are masked so the output can be shared safely.
+func (cfg *config) describe() map[string]any {
+	endpoints := make(map[string]any, len(cfg.endpoints))
+	for sig, ec := range cfg.endpoints {
+		desc := map[string]any{
+			"response_file":         ec.responseFile,
+			"response_content_type": ec.responseContentType,
+			"status":                ec.status,
+		}
+		if ec.latency != nil {
+			desc["latency"] = ec.latency.String()
+		}
+		if ec.errorRate != nil {
+			desc["error_rate"] = *ec.errorRate
+		}
+		endpoints[sig.String()] = desc
+	}
+	corsOrigins := []string{"*"}
+	if len(cfg.cors.origins) > 0 {
+		corsOrigins = slices.Sorted(maps.Keys(cfg.cors.origins))
+	}
+
+	return map[string]any{
+		"mode":        cfg.mode,
+		"addr":        cfg.addr,
+		"unix":        cfg.unixSocket,
+		"grpc_addr":   cfg.grpcAddr,
+		"path_prefix": cfg.pathPrefix,
+		"tls_enabled": cfg.tlsEnabled(),
+		"tls_cert":    cfg.tlsCert,
+		"h2c":         cfg.h2c,
+		"ws":          cfg.ws,
+		"timeouts": map[string]string{
+			"shutdown":    cfg.shutdownTimeout.String(),
+			"read":        cfg.readTimeout.String(),
+			"write":       cfg.writeTimeout.String(),
+			"idle":        cfg.idleTimeout.String(),
+			"read_header": cfg.readHeaderTimeout.String(),
+		},
+
+		"reload_config":          cfg.reloadConfig,
+		"retry_after":            cfg.retryAfter.String(),
+		"status_dist":            cfg.statusDist.String(),
+		"partial_reject":         cfg.partialReject,
+		"partial_reject_message": cfg.partialMessage,
+		"response_size":          cfg.responseSize.String(),
+		"gzip_min_size":          cfg.gzipMinSize.String(),
+		"endpoints":              endpoints,
+
+		"hold_duration":      cfg.holdDuration.String(),
+		"hold_max":           cfg.holdMax.String(),
+		"max_body":           cfg.maxBody.String(),
+		"read_throttle":      cfg.readThrottle.String(),
+		"body_deadline":      cfg.bodyDeadline.String(),
+		"content_types":      slices.Sorted(maps.Keys(cfg.contentTypes)),
+		"strict":             cfg.strict,
+		"require_user_agent": cfg.requireUserAgent,
+		"cors_origins":       corsOrigins,
+		"auth_enabled":       cfg.authToken != "",
+		"auth_token":         maskSecret(cfg.authToken),
+		"admin_token":        maskSecret(cfg.adminToken),
+		"rate":               cfg.rate,
+		"burst":              cfg.burst,
+		"max_concurrent":     cfg.maxConcurrent,
+
+		"dump_dir":          cfg.dumpDir,
+		"capture_dir":       cfg.captureDir,
+		"capture_max_size":  cfg.captureMaxSize.String(),
+		"capture_flush":     cfg.captureFlush.String(),
+		"recent_size":       cfg.recentSize,
+		"max_tenants":       cfg.maxTenants,
+		"dedup_window":      cfg.dedupWindow.String(),
+		"dedup_size":        cfg.dedupSize,
+		"track_attrs":       append([]string{}, cfg.trackAttrs...),
+		"track_attrs_limit": cfg.trackLimit,
+		"report_interval":   cfg.reportInterval.String(),
+		"fast_request_ids":  cfg.fastRequestIDs,
+		"log_format":        cfg.logFormat,
+		"log_level":         cfg.logLevel.String(),
+		"pprof":             cfg.pprof,
+		"verbose":           cfg.verbose,
+	}
+}
+
+// maskSecret hides a secret value, keeping only its length.
+func maskSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "****** (" + strconv.Itoa(len(s)) + " chars)"
+}
+
+// handleConfig reports the resolved settings, with the fault settings as
+// currently in effect after any reload.
+func (a *app) handleConfig(w http.ResponseWriter, r *http.Request) {
+	desc := a.cfg.describe()
+	f := a.faults.Load()
+	desc["latency"] = f.latency.String()
+	desc["error_rate"] = f.errorRate
+	desc["drop_rate"] = f.dropRate
+	writeJSON(w, http.StatusOK, desc)
+}
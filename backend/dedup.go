@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// dupDetector flags request bodies whose hash was already seen within the
+// window. At most limit hashes are remembered; the oldest are forgotten
+// first.
+type dupDetector struct {
+	window time.Duration
+	limit  int
+
+	mu    sync.Mutex
+	seen  map[uint64]*dupEntry
+	order []uint64 // hashes in first-seen order
+}
+
+type dupEntry struct {
+	first time.Time
+	count int
+}
+
+func newDupDetector(window time.Duration, limit int) *dupDetector {
+	return &dupDetector{
+		window: window,
+		limit:  limit,
+		seen:   make(map[uint64]*dupEntry),
+	}
+}
+
+// observe records body as sent by tenant to the endpoint for sig, and returns
+// its hash and how many times it has been seen within the window, including
+// this time. The same body sent to another endpoint or by another tenant is
+// not a duplicate.
+func (d *dupDetector) observe(tenant string, sig otlpSignal, body []byte, now time.Time) (hash uint64, count int, age time.Duration) {
+	h := xxhash.New()
+	_, _ = h.WriteString(tenant)
+	_, _ = h.Write([]byte{0, byte(sig)})
+	_, _ = h.Write(body)
+	hash = h.Sum64()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.evict(now)
+	if e, ok := d.seen[hash]; ok {
+		e.count++
+		return hash, e.count, now.Sub(e.first)
+	}
+	if len(d.seen) >= d.limit {
+		d.forgetOldest()
+	}
+	d.seen[hash] = &dupEntry{first: now, count: 1}
+	d.order = append(d.order, hash)
+	return hash, 1, 0
+}
+
+// evict forgets hashes first seen before the window.
+func (d *dupDetector) evict(now time.Time) {
+	cutoff := now.Add(-d.window)
+	for len(d.order) > 0 && d.seen[d.order[0]].first.Before(cutoff) {
+		d.forgetOldest()
+	}
+}
+
+func (d *dupDetector) forgetOldest() {
+	delete(d.seen, d.order[0])
+	d.order = d.order[1:]
+}
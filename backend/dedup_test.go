@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDuplicatesAreScopedToEndpointAndTenant(t *testing.T) {
+	a, srv := newTestApp(t, &config{dedupWindow: time.Minute, dedupSize: 100})
+	post := func(path, tenant string) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, srv.URL+path, strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", contentTypeJSON)
+		if tenant != "" {
+			req.Header.Set(tenantHeader, tenant)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	post("/v1/traces", "")
+	post("/v1/logs", "")
+	post("/v1/traces", "foo")
+	if got := a.stats.duplicates.Load(); got != 0 {
+		t.Fatalf("same body to another endpoint or tenant: got %d duplicates, want 0", got)
+	}
+
+	post("/v1/traces", "")
+	if got := a.stats.duplicates.Load(); got != 1 {
+		t.Errorf("same body to the same endpoint: got %d duplicates, want 1", got)
+	}
+	foo, _ := a.tenants.lookup("foo")
+	if got := foo.stats.duplicates.Load(); got != 0 {
+		t.Errorf("tenant foo: got %d duplicates, want 0", got)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// dumper writes raw request bodies to files for later inspection or replay.
+type dumper struct {
+	dir string
+	seq atomic.Uint64
+}
+
+func newDumper(dir string) (*dumper, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &dumper{dir: dir}, nil
+}
+
+// contentTypeSuffix names the file next to a dump that holds its exact
+// Content-Type, for types the file extension cannot reproduce.
+const contentTypeSuffix = ".content-type"
+
+// dump writes raw to a new file and returns its path. The file name encodes
+// the signal, a sequence number, the receive time, and the content type and
+// encoding, e.g. traces-000042-1700000000000000000.pb.gz. Content types other
+// than bare OTLP/protobuf and OTLP/JSON, such as ones with a charset, are
+// also written to a path+contentTypeSuffix file.
+func (d *dumper) dump(sig otlpSignal, contentType, contentEncoding string, raw []byte) (string, error) {
+	ext := dumpExt(contentType, contentEncoding)
+	name := fmt.Sprintf("%s-%06d-%d%s", sig, d.seq.Add(1), time.Now().UnixNano(), ext)
+	path := filepath.Join(d.dir, name)
+	if typ, _, _ := strings.Cut(ext[1:], "."); contentType != extContentType(typ) {
+		if err := os.WriteFile(path+contentTypeSuffix, []byte(contentType), 0o644); err != nil {
+			return "", err
+		}
+	}
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// dumpExt returns the file extension recording the payload content type and
+// content encoding.
+func dumpExt(contentType, contentEncoding string) string {
+	ext := ".bin"
+	switch mediaType, _, _ := mime.ParseMediaType(contentType); mediaType {
+	case contentTypeProtobuf:
+		ext = ".pb"
+	case contentTypeJSON:
+		ext = ".json"
+	}
+	if contentEncoding != "" && contentEncoding != "identity" {
+		ext += "." + encodingExt(contentEncoding)
+	}
+	return ext
+}
+
+func encodingExt(contentEncoding string) string {
+	if contentEncoding == "gzip" {
+		return "gz"
+	}
+	return contentEncoding
+}
+
+// dumpFile describes a payload written by dumper, as recovered from its file
+// name.
+type dumpFile struct {
+	path            string
+	signal          otlpSignal
+	received        int64 // Unix nanoseconds
+	contentType     string
+	contentEncoding string
+}
+
+// parseDumpName parses a file name produced by dumper.dump. It reports false
+// for names that do not follow the dump naming scheme.
+func parseDumpName(name string) (dumpFile, bool) {
+	base, ext, _ := strings.Cut(name, ".")
+	parts := strings.Split(base, "-")
+	if len(parts) != 3 {
+		return dumpFile{}, false
+	}
+	var f dumpFile
+	switch parts[0] {
+	case signalTraces.String():
+		f.signal = signalTraces
+	case signalLogs.String():
+		f.signal = signalLogs
+	case signalMetrics.String():
+		f.signal = signalMetrics
+	default:
+		return dumpFile{}, false
+	}
+	received, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return dumpFile{}, false
+	}
+	f.received = received
+
+	typ, enc, _ := strings.Cut(ext, ".")
+	if f.contentType = extContentType(typ); f.contentType == "" {
+		return dumpFile{}, false
+	}
+	if enc == "gz" {
+		enc = "gzip"
+	}
+	f.contentEncoding = enc
+	return f, true
+}
+
+// extContentType returns the content type a dump extension stands for, or ""
+// for an unknown extension.
+func extContentType(ext string) string {
+	switch ext {
+	case "pb":
+		return contentTypeProtobuf
+	case "json":
+		return contentTypeJSON
+	case "bin":
+		return "application/octet-stream"
+	}
+	return ""
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// eventHub fans out ingest events to connected Server-Sent Events clients.
+// Each client has a buffered channel; when it is full the event is dropped
+// for that client rather than blocking the ingest handler.
+type eventHub struct {
+	mu      sync.RWMutex
+	clients map[chan []byte]struct{}
+	active  atomic.Int64
+	dropped atomic.Int64
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+const eventClientBuffer = 256
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		clients: make(map[chan []byte]struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// publish sends v as JSON to every connected client.
+func (h *eventHub) publish(v any) {
+	if h.active.Load() == 0 {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for ch := range h.clients {
+		select {
+		case ch <- data:
+		default:
+			h.dropped.Add(1)
+		}
+	}
+}
+
+// close disconnects all clients; it is registered to run on server shutdown
+// so open streams do not hold up the graceful drain.
+func (h *eventHub) close() {
+	h.closeOnce.Do(func() { close(h.done) })
+}
+
+func (h *eventHub) subscribe() chan []byte {
+	ch := make(chan []byte, eventClientBuffer)
+	h.mu.Lock()
+	h.clients[ch] = struct{}{}
+	h.mu.Unlock()
+	h.active.Add(1)
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan []byte) {
+	h.mu.Lock()
+	delete(h.clients, ch)
+	h.mu.Unlock()
+	h.active.Add(-1)
+}
+
+// ServeHTTP streams events to the client until it disconnects.
+func (h *eventHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	// The stream is long-lived, so lift the server's write timeout.
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	ch := h.subscribe()
+	defer h.unsubscribe(ch)
+	slog.Info("events client connected", "remote", r.RemoteAddr)
+	defer slog.Info("events client disconnected", "remote", r.RemoteAddr)
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.done:
+			return
+		case data := <-ch:
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
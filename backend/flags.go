@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationRange is a flag value holding either a fixed duration ("250ms") or
+// a uniform jitter range ("100ms-400ms").
+type durationRange struct {
+	min, max time.Duration
+}
+
+func (d *durationRange) String() string {
+	if d.min == d.max {
+		return d.min.String()
+	}
+	return d.min.String() + "-" + d.max.String()
+}
+
+func (d *durationRange) Set(s string) error {
+	first, second, isRange := strings.Cut(s, "-")
+	lo, err := time.ParseDuration(first)
+	if err != nil {
+		return err
+	}
+	hi := lo
+	if isRange {
+		if hi, err = time.ParseDuration(second); err != nil {
+			return err
+		}
+	}
+	if lo < 0 || hi < lo {
+		return fmt.Errorf("invalid duration range %q", s)
+	}
+	d.min, d.max = lo, hi
+	return nil
+}
+
+// pick returns a duration chosen uniformly from the range.
+func (d *durationRange) pick() time.Duration {
+	if d.max <= d.min {
+		return d.min
+	}
+	return d.min + rand.N(d.max-d.min+1)
+}
+
+// byteSize is a flag value holding a size in bytes. It accepts plain numbers
+// and the binary suffixes KiB, MiB, and GiB, e.g. "4MiB".
+type byteSize int64
+
+var byteSizeUnits = []struct {
+	suffix string
+	scale  int64
+}{
+	{"GiB", 1 << 30},
+	{"MiB", 1 << 20},
+	{"KiB", 1 << 10},
+	{"B", 1},
+}
+
+func (b byteSize) String() string {
+	for _, u := range byteSizeUnits {
+		if n := int64(b); n != 0 && n%u.scale == 0 {
+			return strconv.FormatInt(n/u.scale, 10) + u.suffix
+		}
+	}
+	return "0"
+}
+
+func (b *byteSize) Set(s string) error {
+	num, scale := s, int64(1)
+	for _, u := range byteSizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			num, scale = strings.TrimSuffix(s, u.suffix), u.scale
+			break
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(num), 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid byte size %q", s)
+	}
+	*b = byteSize(n * scale)
+	return nil
+}
+
+// sizeRange is a flag value holding either a fixed byte size ("4KiB") or a
+// uniform range ("1KiB-64KiB").
+type sizeRange struct {
+	min, max byteSize
+}
+
+func (r *sizeRange) String() string {
+	if r.min == r.max {
+		return r.min.String()
+	}
+	return r.min.String() + "-" + r.max.String()
+}
+
+func (r *sizeRange) Set(s string) error {
+	first, second, isRange := strings.Cut(s, "-")
+	var lo, hi byteSize
+	if err := lo.Set(first); err != nil {
+		return err
+	}
+	hi = lo
+	if isRange {
+		if err := hi.Set(second); err != nil {
+			return err
+		}
+	}
+	if hi < lo {
+		return fmt.Errorf("invalid size range %q", s)
+	}
+	r.min, r.max = lo, hi
+	return nil
+}
+
+// pick returns a size chosen uniformly from the range.
+func (r *sizeRange) pick() int {
+	if r.max <= r.min {
+		return int(r.min)
+	}
+	return int(r.min + rand.N(r.max-r.min+1))
+}
+
+// statusDist is a flag value holding a weighted distribution of HTTP
+// statuses, e.g. "200:90,429:5,503:3,500:2". The weights are percentages
+// and must add up to 100.
+type statusDist []statusWeight
+
+type statusWeight struct {
+	status int
+	weight int
+}
+
+func (d *statusDist) String() string {
+	parts := make([]string, len(*d))
+	for i, sw := range *d {
+		parts[i] = strconv.Itoa(sw.status) + ":" + strconv.Itoa(sw.weight)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (d *statusDist) Set(s string) error {
+	var dist statusDist
+	var total int
+	for _, part := range strings.Split(s, ",") {
+		code, weight, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok {
+			return fmt.Errorf("invalid status weight %q, want status:weight", part)
+		}
+		sw := statusWeight{}
+		var err error
+		if sw.status, err = strconv.Atoi(code); err != nil || sw.status < 200 || sw.status > 599 {
+			return fmt.Errorf("invalid status %q", code)
+		}
+		if sw.weight, err = strconv.Atoi(weight); err != nil || sw.weight < 0 {
+			return fmt.Errorf("invalid weight %q for status %d", weight, sw.status)
+		}
+		total += sw.weight
+		dist = append(dist, sw)
+	}
+	if total != 100 {
+		return fmt.Errorf("status weights add up to %d, want 100", total)
+	}
+	*d = dist
+	return nil
+}
+
+// pick returns a status chosen according to the weights.
+func (d statusDist) pick() int {
+	n := rand.N(100)
+	for _, sw := range d {
+		if n < sw.weight {
+			return sw.status
+		}
+		n -= sw.weight
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+// newGRPCServer returns a gRPC server implementing the OTLP trace, logs, and
+// metrics services. Every export is acknowledged and recorded in st under the
+// same counters as the matching HTTP endpoint.
+func newGRPCServer(st *stats) *grpc.Server {
+	s := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(s, &grpcTraceService{st: st})
+	collogspb.RegisterLogsServiceServer(s, &grpcLogsService{st: st})
+	colmetricspb.RegisterMetricsServiceServer(s, &grpcMetricsService{st: st})
+	return s
+}
+
+type grpcTraceService struct {
+	coltracepb.UnimplementedTraceServiceServer
+	st *stats
+}
+
+func (s *grpcTraceService) Export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	recordGRPCExport(ctx, s.st, signalTraces, req)
+	return new(coltracepb.ExportTraceServiceResponse), nil
+}
+
+type grpcLogsService struct {
+	collogspb.UnimplementedLogsServiceServer
+	st *stats
+}
+
+func (s *grpcLogsService) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	recordGRPCExport(ctx, s.st, signalLogs, req)
+	return new(collogspb.ExportLogsServiceResponse), nil
+}
+
+type grpcMetricsService struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+	st *stats
+}
+
+func (s *grpcMetricsService) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	recordGRPCExport(ctx, s.st, signalMetrics, req)
+	return new(colmetricspb.ExportMetricsServiceResponse), nil
+}
+
+func recordGRPCExport(ctx context.Context, st *stats, sig otlpSignal, req proto.Message) {
+	size := proto.Size(req)
+	if es := st.signal(sig); es != nil {
+		es.record(size)
+	}
+	st.observe(req)
+
+	var userAgent string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		userAgent = strings.Join(md.Get("user-agent"), " ")
+	}
+	st.userAgents.add(userAgent)
+
+	remote := "unknown"
+	if p, ok := peer.FromContext(ctx); ok {
+		remote = p.Addr.String()
+	}
+	method, _ := grpc.Method(ctx)
+	slog.Info("request",
+		"method", "grpc",
+		"path", method,
+		"size_bytes", size,
+		sig.itemsKey(), countMessage(req),
+		"remote", remote,
+		"user_agent", userAgent,
+	)
+}
+
+// stopGRPC stops s gracefully, forcing it closed once ctx is done.
+func stopGRPC(ctx context.Context, s *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.Stop()
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// The histogram uses log-linear buckets in the style of HdrHistogram: each
+// power-of-two range of microseconds is split into histSubBuckets linear
+// buckets, bounding the relative error of a reported quantile to about 6%.
+const (
+	histSubBits    = 4
+	histSubBuckets = 1 << histSubBits
+	histBuckets    = (64 - histSubBits + 1) * histSubBuckets
+)
+
+// latencyHistogram records durations with one atomic add per observation, so
+// it is safe and cheap to update from many handlers at once.
+type latencyHistogram struct {
+	counts [histBuckets]atomic.Uint64
+}
+
+func (h *latencyHistogram) record(d time.Duration) {
+	us := d.Microseconds()
+	if us < 0 {
+		us = 0
+	}
+	h.counts[histBucket(uint64(us))].Add(1)
+}
+
+// quantiles returns the requested quantiles (0..1) of the recorded durations.
+// Each result is the upper bound of the bucket holding that rank.
+func (h *latencyHistogram) quantiles(qs ...float64) []time.Duration {
+	counts := h.load(false)
+	return histQuantiles(&counts, qs...)
+}
+
+// load returns the current bucket counts, zeroing them when reset is set.
+func (h *latencyHistogram) load(reset bool) [histBuckets]uint64 {
+	var counts [histBuckets]uint64
+	for i := range h.counts {
+		if reset {
+			counts[i] = h.counts[i].Swap(0)
+		} else {
+			counts[i] = h.counts[i].Load()
+		}
+	}
+	return counts
+}
+
+func histQuantiles(counts *[histBuckets]uint64, qs ...float64) []time.Duration {
+	var total uint64
+	for _, c := range counts {
+		total += c
+	}
+
+	out := make([]time.Duration, len(qs))
+	if total == 0 {
+		return out
+	}
+	for j, q := range qs {
+		rank := uint64(q*float64(total) + 0.5)
+		rank = min(max(rank, 1), total)
+
+		var seen uint64
+		for i, c := range counts {
+			seen += c
+			if seen >= rank {
+				out[j] = time.Duration(histUpperBound(i)) * time.Microsecond
+				break
+			}
+		}
+	}
+	return out
+}
+
+func histBucket(v uint64) int {
+	if v < histSubBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(v) - 1 - histSubBits
+	sub := int(v>>shift) - histSubBuckets
+	return (shift+1)*histSubBuckets + sub
+}
+
+func histUpperBound(i int) uint64 {
+	if i < histSubBuckets {
+		return uint64(i)
+	}
+	shift := i/histSubBuckets - 1
+	sub := uint64(i % histSubBuckets)
+	return (histSubBuckets+sub+1)<<shift - 1
+}
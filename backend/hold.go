@@ -0,0 +1,34 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// memHold keeps request bodies referenced for a while after they were
+// handled, simulating a collector that buffers exports in memory. The total
+// size of held bodies is capped.
+type memHold struct {
+	ttl   time.Duration
+	limit int64
+	held  atomic.Int64
+}
+
+func newMemHold(ttl time.Duration, limit int64) *memHold {
+	return &memHold{ttl: ttl, limit: limit}
+}
+
+// hold retains body for the TTL. It reports false, retaining nothing, when
+// that would exceed the cap.
+func (h *memHold) hold(body []byte) bool {
+	n := int64(len(body))
+	if h.held.Add(n) > h.limit {
+		h.held.Add(-n)
+		return false
+	}
+	time.AfterFunc(h.ttl, func() {
+		// Referencing body here is what keeps it alive until the timer fires.
+		h.held.Add(-int64(len(body)))
+	})
+	return true
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// app holds the state shared by the HTTP handlers.
+type app struct {
+	cfg     *config
+	stats   *stats
+	prom    *promMetrics
+	dump    *dumper
+	capture *captureSink
+	limit   *rateLimiter
+	recent  *recentBuffer
+	dups    *dupDetector
+	hold    *memHold
+	events  *eventHub
+
+	// tenants keeps separate stats and recent requests per X-Tenant; the
+	// stats and recent fields above always cover all tenants.
+	tenants *tenantRegistry
+
+	// slots bounds the number of concurrently handled OTLP requests when
+	// -max-concurrent is set.
+	slots chan struct{}
+
+	// faults holds the fault injection settings, which SIGHUP can replace.
+	faults atomic.Pointer[faultSettings]
+
+	// ready reports whether the listener is bound and the server is not
+	// shutting down.
+	ready atomic.Bool
+}
+
+// registerEndpoint mounts the OTLP/HTTP ingest handler for sig at path.
+func (a *app) registerEndpoint(mux *http.ServeMux, path string, sig otlpSignal) {
+	cfg := a.cfg
+	ec := cfg.endpoints[sig]
+	es := a.stats.endpoint(path, sig)
+	a.tenants.addEndpoint(path, sig)
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		defer func() { a.prom.observeRequest(path, r.Method, time.Since(start)) }()
+
+		rec := &statusRecorder{ResponseWriter: w, start: start, status: http.StatusOK}
+		w = rec
+
+		cfg.cors.setHeaders(w, r)
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			if !a.authorize(w, r) {
+				return
+			}
+			// HEAD lets monitoring probes check the endpoint without ingesting.
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			w.Header().Set("Allow", "POST, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		tenantName, tn := a.tenants.get(r.Header.Get(tenantHeader))
+		tes := tn.stats.signal(sig)
+		a.stats.begin()
+		defer a.stats.done()
+		tn.stats.begin()
+		defer tn.stats.done()
+
+		requestID := r.Header.Get(requestIDHeader)
+		if requestID == "" {
+			requestID = newRequestID(cfg.fastRequestIDs)
+		}
+		w.Header().Set(requestIDHeader, requestID)
+
+		userAgent := r.UserAgent()
+		a.stats.userAgents.add(userAgent)
+		tn.stats.userAgents.add(userAgent)
+
+		var size int
+		items := -1
+		var extra []any
+		if r.Header.Get(tenantHeader) != "" {
+			extra = append(extra, "tenant", tenantName)
+		}
+		// Every POST is counted here, including those rejected before the
+		// body is read, so counts and latencies cover the same requests.
+		defer func() {
+			elapsed := time.Since(start)
+			es.record(size)
+			es.recordDuration(elapsed)
+			tes.record(size)
+			tes.recordDuration(elapsed)
+
+			entry := &recentRequest{
+				Time:        start,
+				RequestID:   requestID,
+				Path:        r.URL.Path,
+				Signal:      sig.String(),
+				Tenant:      tenantName,
+				SizeBytes:   size,
+				ContentType: r.Header.Get("Content-Type"),
+				Status:      rec.status,
+			}
+			if items >= 0 {
+				entry.Items = &items
+			}
+			a.recent.add(entry)
+			tn.recent.add(entry)
+			a.events.publish(entry)
+
+			args := []any{
+				"method", r.Method,
+				"path", r.URL.Path,
+				"size_bytes", size,
+			}
+			args = append(args, extra...)
+			args = append(args,
+				"remote", r.RemoteAddr,
+				"user_agent", userAgent,
+				"request_id", requestID,
+				"status", rec.status,
+				"duration_ms", durationMs(elapsed),
+			)
+			slog.Info("request", args...)
+		}()
+
+		// The body deadline bounds every read of the body, including the
+		// drains of early rejections and the time spent in -read-throttle.
+		bodyCtx := r.Context()
+		if cfg.bodyDeadline > 0 {
+			var cancel context.CancelFunc
+			bodyCtx, cancel = context.WithTimeout(bodyCtx, cfg.bodyDeadline)
+			defer cancel()
+		}
+		if cfg.readThrottle > 0 {
+			r.Body = throttleBody(bodyCtx, r.Body, int64(cfg.readThrottle))
+		}
+		if cfg.bodyDeadline > 0 {
+			r.Body = newDeadlineBody(bodyCtx, w, r.Body)
+		}
+
+		if !a.authorize(w, r) {
+			return
+		}
+
+		if cfg.requireUserAgent && userAgent == "" {
+			slog.Warn("missing user agent", "path", r.URL.Path, "remote", r.RemoteAddr)
+			drainBody(r, int64(cfg.maxBody))
+			writeJSONError(w, http.StatusBadRequest, "missing User-Agent header")
+			return
+		}
+
+		if a.limit != nil {
+			if ok, wait := a.limit.allow(clientIP(r.RemoteAddr)); !ok {
+				slog.Info("rate limited", "path", r.URL.Path, "remote", r.RemoteAddr, "retry_after", wait.String())
+				drainBody(r, int64(cfg.maxBody))
+				setRetryAfter(w, wait)
+				writeJSONError(w, http.StatusTooManyRequests, "rate limit exceeded")
+				return
+			}
+		}
+
+		// Rate-limited requests are rejected before taking a concurrency slot,
+		// so draining them cannot crowd out requests that are let through.
+		if a.slots != nil {
+			select {
+			case a.slots <- struct{}{}:
+				defer func() { <-a.slots }()
+			default:
+				a.stats.overflows.Add(1)
+				tn.stats.overflows.Add(1)
+				slog.Info("concurrency limit reached", "path", r.URL.Path, "remote", r.RemoteAddr, "max_concurrent", cap(a.slots))
+				drainBody(r, int64(cfg.maxBody))
+				setRetryAfter(w, cfg.retryAfter)
+				writeJSONError(w, http.StatusServiceUnavailable, "too many concurrent requests")
+				return
+			}
+		}
+
+		contentType := r.Header.Get("Content-Type")
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		if !cfg.contentTypes[mediaType] {
+			slog.Warn("unsupported content type", "path", r.URL.Path, "content_type", contentType, "remote", r.RemoteAddr)
+			drainBody(r, int64(cfg.maxBody))
+			writeJSONError(w, http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported content type %q", contentType))
+			return
+		}
+
+		if ec.status != http.StatusOK {
+			drainBody(r, int64(cfg.maxBody))
+			slog.Info("forced status", "path", r.URL.Path, "remote", r.RemoteAddr, "status", ec.status)
+			writeForcedStatus(w, ec.status)
+			return
+		}
+
+		// Read the whole body to allow clients to reuse connections.
+		body, raw, err := readBody(w, r, int64(cfg.maxBody))
+		size = len(body)
+		a.prom.observeBodySize(path, len(body))
+		// Only complete bodies are dumped or captured, so replaying them sends
+		// real payloads.
+		var truncated *truncatedBodyError
+		complete := !errors.As(err, &truncated)
+		if a.dump != nil && complete {
+			if _, err := a.dump.dump(sig, contentType, r.Header.Get("Content-Encoding"), raw); err != nil {
+				slog.Warn("dump failed", "path", r.URL.Path, "error", err)
+			}
+		}
+		if a.capture != nil && complete {
+			if err := a.capture.write(sig, contentType, r.Header.Get("Content-Encoding"), raw); err != nil {
+				slog.Warn("capture failed", "path", r.URL.Path, "error", err)
+			}
+		}
+		if a.dups != nil && err == nil {
+			if hash, count, age := a.dups.observe(tenantName, sig, body, time.Now()); count > 1 {
+				a.stats.duplicates.Add(1)
+				tn.stats.duplicates.Add(1)
+				if count == 2 {
+					slog.Warn("duplicate export", "path", r.URL.Path, "remote", r.RemoteAddr,
+						"hash", fmt.Sprintf("%016x", hash), "first_seen_ago", age.String(), "request_id", requestID)
+				}
+			}
+		}
+		var encErr *unsupportedEncodingError
+		if errors.As(err, &encErr) {
+			slog.Warn("unsupported content encoding", "path", r.URL.Path, "remote", r.RemoteAddr, "encoding", encErr.encoding)
+			writeJSONError(w, http.StatusUnsupportedMediaType, err.Error())
+			return
+		}
+		if errors.Is(err, errBodyDeadline) {
+			slog.Warn("body deadline exceeded", "path", r.URL.Path, "remote", r.RemoteAddr,
+				"deadline", cfg.bodyDeadline.String(), "received_bytes", len(raw))
+			w.Header().Set("Connection", "close")
+			writeJSONError(w, http.StatusRequestTimeout, "request body not received within "+cfg.bodyDeadline.String())
+			return
+		}
+		if errors.Is(err, errBodyTooLarge) {
+			slog.Warn("request body too large", "path", r.URL.Path, "remote", r.RemoteAddr, "limit", cfg.maxBody.String())
+			writeJSONError(w, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		if err != nil {
+			slog.Warn("read body failed", "path", r.URL.Path, "remote", r.RemoteAddr, "error", err)
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if a.hold != nil && !a.hold.hold(body) {
+			slog.Info("hold limit reached", "path", r.URL.Path, "remote", r.RemoteAddr,
+				"held_bytes", a.hold.held.Load(), "limit", cfg.holdMax.String())
+			setRetryAfter(w, cfg.retryAfter)
+			writeJSONError(w, http.StatusServiceUnavailable, "memory hold limit reached")
+			return
+		}
+
+		if enc := contentEncoding(r); enc != "" {
+			extra = append(extra, "encoding", enc, "compressed_bytes", len(raw))
+		}
+
+		var n int
+		msg, err := decodeRequest(sig, mediaType, body)
+		if err == nil && msg != nil && cfg.strict {
+			err = validateRequest(msg, mediaType == contentTypeProtobuf)
+			if err != nil {
+				slog.Warn("validation failed", "path", r.URL.Path, "remote", r.RemoteAddr, "reason", err)
+				writeJSONError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		switch {
+		case err != nil && (cfg.strict || mediaType == contentTypeJSON):
+			slog.Warn("decode failed", "path", r.URL.Path, "remote", r.RemoteAddr, "error", err)
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		case err != nil:
+			slog.Warn("decode failed", "path", r.URL.Path, "remote", r.RemoteAddr, "error", err)
+		case msg != nil:
+			a.stats.observe(msg)
+			tn.stats.observe(msg)
+			n = countMessage(msg)
+			items = n
+			extra = append(extra, sig.itemsKey(), n)
+		}
+
+		faults := ec.faults(a.faults.Load())
+		if d := faults.latency.pick(); d > 0 {
+			time.Sleep(d)
+		}
+
+		if faults.dropRate > 0 && rand.Float64() < faults.dropRate {
+			slog.Info("dropping connection", "path", r.URL.Path, "remote", r.RemoteAddr)
+			rec.status = 0
+			dropConnection(w)
+			return
+		}
+
+		if faults.errorRate > 0 && rand.Float64() < faults.errorRate {
+			slog.Info("injected error", "path", r.URL.Path, "status", http.StatusServiceUnavailable, "remote", r.RemoteAddr)
+			setRetryAfter(w, cfg.retryAfter)
+			writeJSONError(w, http.StatusServiceUnavailable, "injected error")
+			return
+		}
+
+		if len(cfg.statusDist) > 0 {
+			if status := cfg.statusDist.pick(); status != http.StatusOK {
+				slog.Info("injected status", "path", r.URL.Path, "status", status, "remote", r.RemoteAddr)
+				if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+					setRetryAfter(w, cfg.retryAfter)
+				}
+				writeForcedStatus(w, status)
+				return
+			}
+		}
+
+		// Export responses are OTLP/JSON unless the client asks for protobuf.
+		w.Header().Add("Vary", "Accept")
+		wantProtobuf := acceptsProtobuf(r)
+
+		if cfg.partialReject > 0 {
+			rejected := cfg.partialReject
+			if msg != nil && int64(n) < rejected {
+				rejected = int64(n)
+			}
+			slog.Info("partial success", "path", r.URL.Path, "rejected", rejected, "remote", r.RemoteAddr)
+			writeExportResponse(w, r, newPartialSuccessResponse(sig, rejected, cfg.partialMessage), wantProtobuf, int64(cfg.gzipMinSize))
+			return
+		}
+
+		if ec.response != nil {
+			writeBody(w, r, http.StatusOK, ec.responseContentType, ec.response, int64(cfg.gzipMinSize))
+			return
+		}
+
+		if cfg.responseSize.max > 0 {
+			// Padding is sent as is, so clients see exactly the chosen size.
+			padTo := cfg.responseSize.pick()
+			contentType, resp := contentTypeJSON, paddedResponse(padTo)
+			if wantProtobuf {
+				contentType, resp = contentTypeProtobuf, paddedProtobufResponse(padTo)
+			}
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Length", strconv.Itoa(len(resp)))
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(resp)
+			return
+		}
+
+		if wantProtobuf {
+			writeExportResponse(w, r, newExportResponse(sig), true, int64(cfg.gzipMinSize))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("{}"))
+	})
+}
+
+// authorize checks the bearer token of an OTLP request, answering 401 when it
+// is missing or wrong.
+func (a *app) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if checkToken(r, a.cfg.authToken) {
+		return true
+	}
+	slog.Warn("auth failed", "path", r.URL.Path, "remote", r.RemoteAddr, "has_authorization", r.Header.Get("Authorization") != "")
+	drainBody(r, int64(a.cfg.maxBody))
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	writeJSONError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+	return false
+}
+
+// writeForcedStatus answers with a -<signal>-status override. Error statuses
+// carry the usual JSON error body and success statuses an empty object.
+func writeForcedStatus(w http.ResponseWriter, status int) {
+	switch {
+	case status >= 400:
+		writeJSONError(w, status, fmt.Sprintf("forced %d %s", status, http.StatusText(status)))
+	case status == http.StatusNoContent || status == http.StatusNotModified:
+		w.WriteHeader(status)
+	default:
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("{}"))
+	}
+}
+
+func (a *app) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !a.ready.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"status":"unavailable"}`))
+		return
+	}
+	_, _ = w.Write([]byte(`{"status":"ok"}`))
+}
+
+// dropConnection closes the client connection without writing a response.
+// HTTP/1.x connections are hijacked and reset; connections that cannot be
+// hijacked, such as HTTP/2 streams, are aborted instead.
+func dropConnection(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tc, ok := conn.(*net.TCPConn); ok {
+		// Discard unsent data and send RST instead of a graceful FIN.
+		_ = tc.SetLinger(0)
+	}
+	_ = conn.Close()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestApp returns an app serving the OTLP endpoints with cfg, which only
+// needs the settings a test cares about.
+func newTestApp(t *testing.T, cfg *config) (*app, *httptest.Server) {
+	t.Helper()
+	if cfg.endpoints == nil {
+		cfg.endpoints = make(map[otlpSignal]*endpointConfig)
+	}
+	for _, sig := range allSignals {
+		if cfg.endpoints[sig] == nil {
+			cfg.endpoints[sig] = &endpointConfig{status: http.StatusOK}
+		}
+	}
+	if cfg.contentTypes == nil {
+		cfg.contentTypes = map[string]bool{contentTypeProtobuf: true, contentTypeJSON: true}
+	}
+	if cfg.recentSize == 0 {
+		cfg.recentSize = 10
+	}
+	if cfg.maxTenants == 0 {
+		cfg.maxTenants = 10
+	}
+
+	a := &app{
+		cfg:     cfg,
+		stats:   newStats(),
+		prom:    newPromMetrics(),
+		recent:  newRecentBuffer(cfg.recentSize),
+		tenants: newTenantRegistry(cfg.maxTenants, cfg.recentSize),
+		events:  newEventHub(),
+	}
+	a.faults.Store(cfg.faultSettings())
+	if cfg.rate > 0 {
+		a.limit = newRateLimiter(cfg.rate, cfg.burst)
+	}
+	if cfg.dedupWindow > 0 {
+		a.dups = newDupDetector(cfg.dedupWindow, cfg.dedupSize)
+	}
+
+	mux := http.NewServeMux()
+	a.registerEndpoint(mux, "/v1/traces", signalTraces)
+	a.registerEndpoint(mux, "/v1/logs", signalLogs)
+	a.registerEndpoint(mux, "/v1/metrics", signalMetrics)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return a, srv
+}
+
+func postJSON(t *testing.T, url, body string) int {
+	t.Helper()
+	resp, err := http.Post(url, contentTypeJSON, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestBodyDeadlineBoundsRateLimitedDrain(t *testing.T) {
+	_, srv := newTestApp(t, &config{
+		bodyDeadline: 200 * time.Millisecond,
+		rate:         0.001,
+		burst:        1,
+	})
+	if code := postJSON(t, srv.URL+"/v1/traces", "{}"); code != http.StatusOK {
+		t.Fatalf("first request: got status %d, want 200", code)
+	}
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// Announce a body but send only part of it, then stall.
+	_, err = conn.Write([]byte("POST /v1/traces HTTP/1.1\r\nHost: test\r\n" +
+		"Content-Type: application/json\r\nContent-Length: 1000\r\n\r\n{\"resourceSpans\":"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_ = conn.SetReadDeadline(start.Add(5 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("no response to the stalled body: %v", err)
+	}
+	_ = resp.Body.Close()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("response took %s, want about the 200ms body deadline", elapsed)
+	}
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("got status %d, want 429", resp.StatusCode)
+	}
+	if !resp.Close {
+		t.Error("connection not closed after the body deadline cut the read short")
+	}
+}
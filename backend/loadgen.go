@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// loadgenResults accumulates the outcome of generated exports.
+type loadgenResults struct {
+	sent      atomic.Int64
+	succeeded atomic.Int64
+	httpErrs  atomic.Int64
+	netErrs   atomic.Int64
+	bytes     atomic.Int64
+	spans     atomic.Int64
+	latency   latencyHistogram
+
+	mu       sync.Mutex
+	statuses map[int]int64
+}
+
+func (res *loadgenResults) recordStatus(code int) {
+	res.mu.Lock()
+	res.statuses[code]++
+	res.mu.Unlock()
+}
+
+// runLoadgen sends synthetic OTLP/protobuf trace exports to cfg.target until
+// cfg.duration elapses or the process is interrupted, then prints a summary.
+func runLoadgen(cfg *config) error {
+	target, err := loadgenTarget(cfg.target, "/v1/traces")
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if cfg.duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.duration)
+		defer cancel()
+	}
+
+	client := newLoadgenClient(cfg)
+	res := &loadgenResults{statuses: make(map[int]int64)}
+	tokens := pace(ctx, cfg.sendRate)
+
+	fmt.Printf("loadgen: target=%s concurrency=%d rate=%g duration=%s spans_per_request=%d\n",
+		target, cfg.concurrency, cfg.sendRate, cfg.duration, cfg.spansPerRequest)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range cfg.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if tokens != nil {
+					if _, ok := <-tokens; !ok {
+						return
+					}
+				}
+				if ctx.Err() != nil {
+					return
+				}
+				sendExport(ctx, client, target, cfg.authToken, cfg.spansPerRequest, res)
+			}
+		}()
+	}
+	wg.Wait()
+
+	printLoadgenSummary(res, time.Since(start))
+	return nil
+}
+
+// newLoadgenClient returns the HTTP client used to send exports. With -unix
+// set, every request is sent over that socket whatever the target host.
+func newLoadgenClient(cfg *config) *http.Client {
+	transport := &http.Transport{
+		MaxIdleConns:        cfg.concurrency,
+		MaxIdleConnsPerHost: cfg.concurrency,
+	}
+	if cfg.unixSocket != "" {
+		var d net.Dialer
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return d.DialContext(ctx, "unix", cfg.unixSocket)
+		}
+	}
+	return &http.Client{Timeout: 30 * time.Second, Transport: transport}
+}
+
+// pace returns a channel yielding rate tokens per second, or nil when rate
+// is not positive. The channel is closed when ctx is done.
+func pace(ctx context.Context, rate float64) <-chan struct{} {
+	if rate <= 0 {
+		return nil
+	}
+	tokens := make(chan struct{})
+	go func() {
+		defer close(tokens)
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				select {
+				case tokens <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return tokens
+}
+
+func sendExport(ctx context.Context, client *http.Client, target, token string, spans int, res *loadgenResults) {
+	body, err := proto.Marshal(newSyntheticTraceRequest(spans))
+	if err != nil {
+		res.netErrs.Add(1)
+		return
+	}
+	header := http.Header{"Content-Type": {contentTypeProtobuf}}
+	if postExport(ctx, client, target, token, header, body, res) {
+		res.spans.Add(int64(spans))
+	}
+}
+
+// postExport sends body with the given headers to target and records the
+// outcome in res. It reports whether the target answered with a 2xx status.
+func postExport(ctx context.Context, client *http.Client, target, token string, header http.Header, body []byte, res *loadgenResults) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		res.netErrs.Add(1)
+		return false
+	}
+	maps.Copy(req.Header, header)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() == nil {
+			res.sent.Add(1)
+			res.netErrs.Add(1)
+		}
+		return false
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	res.sent.Add(1)
+	res.latency.record(time.Since(start))
+	res.bytes.Add(int64(len(body)))
+	res.recordStatus(resp.StatusCode)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		res.succeeded.Add(1)
+		return true
+	}
+	res.httpErrs.Add(1)
+	return false
+}
+
+func printLoadgenSummary(res *loadgenResults, elapsed time.Duration) {
+	secs := elapsed.Seconds()
+	sent := res.sent.Load()
+	q := res.latency.quantiles(0.5, 0.9, 0.99)
+
+	fmt.Printf("elapsed:       %s\n", elapsed.Round(time.Millisecond))
+	fmt.Printf("requests:      %d (%.1f/s)\n", sent, float64(sent)/secs)
+	fmt.Printf("succeeded:     %d\n", res.succeeded.Load())
+	fmt.Printf("http errors:   %d\n", res.httpErrs.Load())
+	fmt.Printf("net errors:    %d\n", res.netErrs.Load())
+	fmt.Printf("spans:         %d (%.1f/s)\n", res.spans.Load(), float64(res.spans.Load())/secs)
+	fmt.Printf("bytes:         %d (%.1f KiB/s)\n", res.bytes.Load(), float64(res.bytes.Load())/1024/secs)
+	fmt.Printf("latency:       p50=%s p90=%s p99=%s\n", q[0], q[1], q[2])
+	printStatuses(res)
+}
+
+func printStatuses(res *loadgenResults) {
+	res.mu.Lock()
+	defer res.mu.Unlock()
+	for _, code := range slices.Sorted(maps.Keys(res.statuses)) {
+		fmt.Printf("status %d:    %d\n", code, res.statuses[code])
+	}
+}
+
+// loadgenTarget resolves the export URL, appending defaultPath when target
+// has no path of its own.
+func loadgenTarget(target, defaultPath string) (string, error) {
+	if target == "" {
+		return "", fmt.Errorf("-target is required")
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("invalid -target: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid -target %q: scheme must be http or https", target)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = defaultPath
+	}
+	return u.String(), nil
+}
+
+// newSyntheticTraceRequest returns an export request with n spans of a
+// single trace.
+func newSyntheticTraceRequest(n int) *coltracepb.ExportTraceServiceRequest {
+	traceID := randomBytes(16)
+	now := time.Now()
+
+	spans := make([]*tracepb.Span, n)
+	for i := range spans {
+		spans[i] = &tracepb.Span{
+			TraceId:           traceID,
+			SpanId:            randomBytes(8),
+			Name:              "GET /api/loadgen",
+			Kind:              tracepb.Span_SPAN_KIND_CLIENT,
+			StartTimeUnixNano: uint64(now.Add(-50 * time.Millisecond).UnixNano()),
+			EndTimeUnixNano:   uint64(now.UnixNano()),
+			Attributes: []*commonpb.KeyValue{
+				stringAttr("http.method", "GET"),
+				stringAttr("http.route", "/api/loadgen"),
+			},
+		}
+	}
+
+	return &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			Resource: &resourcepb.Resource{
+				Attributes: []*commonpb.KeyValue{stringAttr("service.name", "otlp-mock-loadgen")},
+			},
+			ScopeSpans: []*tracepb.ScopeSpans{{
+				Scope: &commonpb.InstrumentationScope{Name: "loadgen", Version: "1.0.0"},
+				Spans: spans,
+			}},
+		}},
+	}
+}
+
+func stringAttr(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}},
+	}
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return b
+}
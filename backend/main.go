@@ -1,53 +1,295 @@
 package main
 
 import (
-	"io"
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
 )
 
 func main() {
-	mux := http.NewServeMux()
-	registerEndpoint := func(path string) {
-		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "*")
-			w.Header().Set("Access-Control-Max-Age", "86400")
-
-			if r.Method == http.MethodOptions {
-				w.WriteHeader(http.StatusNoContent)
-				return
-			}
+	cfg := parseConfig()
+
+	if cfg.mode == "loadgen" {
+		if err := runLoadgen(cfg); err != nil {
+			fatal("loadgen", "error", err)
+		}
+		return
+	}
+	if cfg.mode == "replay" {
+		if err := runReplay(cfg); err != nil {
+			fatal("replay", "error", err)
+		}
+		return
+	}
+
+	a := &app{
+		cfg:     cfg,
+		stats:   newStats(),
+		prom:    newPromMetrics(),
+		recent:  newRecentBuffer(cfg.recentSize),
+		tenants: newTenantRegistry(cfg.maxTenants, cfg.recentSize),
+		events:  newEventHub(),
+	}
+	a.faults.Store(cfg.faultSettings())
+	for _, sig := range allSignals {
+		ec := cfg.endpoints[sig]
+		if ec.status != http.StatusOK {
+			slog.Warn("forcing response status", "signal", sig.String(), "status", ec.status)
+		}
+		var overrides []any
+		if ec.latency != nil {
+			overrides = append(overrides, "latency", ec.latency.String())
+		}
+		if ec.errorRate != nil {
+			overrides = append(overrides, "error_rate", *ec.errorRate)
+		}
+		if overrides != nil {
+			slog.Info("endpoint overrides", append([]any{"signal", sig.String()}, overrides...)...)
+		}
+	}
+	if cfg.holdDuration > 0 {
+		a.hold = newMemHold(cfg.holdDuration, int64(cfg.holdMax))
+		slog.Info("holding request bodies", "ttl", cfg.holdDuration.String(), "limit", cfg.holdMax.String())
+	}
+	if cfg.dedupWindow > 0 {
+		a.dups = newDupDetector(cfg.dedupWindow, cfg.dedupSize)
+	}
+	if len(cfg.trackAttrs) > 0 {
+		a.stats.attrs = newAttrTracker(cfg.trackAttrs, cfg.trackLimit)
+		a.tenants.trackAttrs(cfg.trackAttrs, cfg.trackLimit)
+	}
+
+	if cfg.dumpDir != "" {
+		var err error
+		if a.dump, err = newDumper(cfg.dumpDir); err != nil {
+			fatal("dump dir", "error", err)
+		}
+		slog.Info("dumping request bodies", "dir", cfg.dumpDir)
+	}
+
+	// exitCode is set by failures that must still run the deferred cleanup,
+	// such as closing the capture sink, before the process exits.
+	var exitCode int
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
 
-			if r.Method != http.MethodPost {
-				w.Header().Set("Allow", http.MethodPost)
-				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-				return
+	captureCtx, stopCapture := context.WithCancel(context.Background())
+	defer stopCapture()
+	if cfg.captureDir != "" {
+		var err error
+		if a.capture, err = newCaptureSink(cfg.captureDir, int64(cfg.captureMaxSize)); err != nil {
+			fatal("capture dir", "error", err)
+		}
+		defer func() {
+			if err := a.capture.close(); err != nil {
+				slog.Error("capture close failed", "error", err)
 			}
+		}()
+		go a.capture.flushLoop(captureCtx, cfg.captureFlush)
+		slog.Info("capturing request bodies", "dir", cfg.captureDir, "max_size", cfg.captureMaxSize.String())
+	}
 
-			// Drain the body to allow clients to reuse connections.
-			n, _ := io.Copy(io.Discard, r.Body)
-			_ = r.Body.Close()
+	if cfg.rate > 0 {
+		a.limit = newRateLimiter(cfg.rate, cfg.burst)
+		slog.Info("rate limiting enabled", "rate", cfg.rate, "burst", cfg.burst)
+	}
 
-			log.Printf("request: method=%s path=%s size_bytes=%d remote=%s", r.Method, r.URL.Path, n, r.RemoteAddr)
+	if cfg.maxConcurrent > 0 {
+		a.slots = make(chan struct{}, cfg.maxConcurrent)
+	}
 
-			w.WriteHeader(http.StatusOK)
-			_, _ = w.Write([]byte("{}"))
-		})
+	mux := http.NewServeMux()
+	a.registerEndpoint(mux, cfg.pathPrefix+"/v1/traces", signalTraces)
+	a.registerEndpoint(mux, cfg.pathPrefix+"/v1/logs", signalLogs)
+	a.registerEndpoint(mux, cfg.pathPrefix+"/v1/metrics", signalMetrics)
+	if cfg.ws {
+		a.registerWebSocket(mux, cfg.pathPrefix+"/v1/traces/ws", signalTraces)
+		slog.Info("websocket ingest enabled", "path", cfg.pathPrefix+"/v1/traces/ws")
 	}
 
-	registerEndpoint("/v1/traces")
-	registerEndpoint("/v1/logs")
-	registerEndpoint("/v1/metrics")
+	mux.HandleFunc("GET /stats", a.handleStats)
+	mux.HandleFunc("GET /recent", a.handleRecent)
+	mux.Handle("GET /events", a.events)
+	mux.HandleFunc("POST /admin/reset", a.handleReset)
+	mux.Handle("GET /metrics", a.prom.handler())
+	mux.HandleFunc("GET /healthz", a.handleHealthz)
+	mux.HandleFunc("GET /{$}", handleDashboard)
+	mux.HandleFunc("GET /debug/config", a.handleConfig)
+	if cfg.pprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		slog.Info("pprof enabled", "path", "/debug/pprof/")
+	}
+
+	var handler http.Handler = mux
+	if cfg.verbose {
+		handler = verboseHandler(handler)
+	}
+	if cfg.h2c {
+		handler = h2c.NewHandler(handler, &http2.Server{})
+	}
 
 	server := &http.Server{
-		Addr:    ":14318",
-		Handler: mux,
+		Addr:              cfg.addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.readTimeout,
+		WriteTimeout:      cfg.writeTimeout,
+		IdleTimeout:       cfg.idleTimeout,
+		ReadHeaderTimeout: cfg.readHeaderTimeout,
+	}
+	if cfg.verbose {
+		server.ConnContext = verboseConnContext
+		server.ConnState = verboseConnState
+	}
+	server.RegisterOnShutdown(a.events.close)
+
+	network, listenAddr := "tcp", server.Addr
+	if cfg.unixSocket != "" {
+		network, listenAddr = "unix", cfg.unixSocket
+		removeStaleSocket(cfg.unixSocket)
 	}
+	ln, err := net.Listen(network, listenAddr)
+	if err != nil {
+		addrFlag := "-addr"
+		if cfg.unixSocket != "" {
+			addrFlag = "-unix"
+		}
+		fatalListen(err, listenAddr, addrFlag)
+	}
+	if cfg.unixSocket != "" {
+		// Shutdown unlinks the socket when it closes the listener; remove it
+		// here as well in case the listener was never closed.
+		defer func() { _ = os.Remove(cfg.unixSocket) }()
+	}
+	a.ready.Store(true)
+
+	scheme := "http"
+	if cfg.tlsEnabled() {
+		scheme = "https"
+	}
+	slog.Info("listening on "+ln.Addr().String(), "network", network, "addr", ln.Addr().String(), "scheme", scheme, "h2c", cfg.h2c, "max_body", cfg.maxBody.String())
+
+	errc := make(chan error, 2)
+	go func() {
+		if cfg.tlsEnabled() {
+			errc <- server.ServeTLS(ln, cfg.tlsCert, cfg.tlsKey)
+			return
+		}
+		errc <- server.Serve(ln)
+	}()
+
+	var grpcServer *grpc.Server
+	if cfg.grpcAddr != "" {
+		gln, err := net.Listen("tcp", cfg.grpcAddr)
+		if err != nil {
+			fatalListen(err, cfg.grpcAddr, "-grpc-addr")
+		}
+		grpcServer = newGRPCServer(a.stats)
+		slog.Info("grpc listening on "+cfg.grpcAddr, "addr", cfg.grpcAddr)
+		go func() {
+			errc <- grpcServer.Serve(gln)
+		}()
+	}
+
+	rp := newReporter(a.stats)
+	reportCtx, stopReports := context.WithCancel(context.Background())
+	defer stopReports()
+	if cfg.reportInterval > 0 {
+		go rp.run(reportCtx, cfg.reportInterval)
+	}
+
+	if cfg.reloadConfig != "" {
+		hupc := make(chan os.Signal, 1)
+		signal.Notify(hupc, syscall.SIGHUP)
+		go func() {
+			for range hupc {
+				a.reloadFaults()
+			}
+		}()
+		slog.Info("reloading fault settings on SIGHUP", "file", cfg.reloadConfig)
+	}
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case err := <-errc:
+		if err != nil && err != http.ErrServerClosed {
+			slog.Error("server error", "error", err)
+			exitCode = 1
+		}
+		return
+	case sig := <-sigc:
+		a.ready.Store(false)
+		slog.Info("shutting down", "signal", sig.String(), "in_flight", a.stats.inFlight.Load(), "grace", cfg.shutdownTimeout.String())
+	}
+	inFlight, finished := a.stats.inFlight.Load(), a.stats.finished.Load()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.shutdownTimeout)
+	defer cancel()
+
+	if grpcServer != nil {
+		stopGRPC(ctx, grpcServer)
+	}
+	err = server.Shutdown(ctx)
+	a.stats.waitIdle(ctx)
+	slog.Info("drain complete",
+		"in_flight_at_shutdown", inFlight,
+		"completed", a.stats.finished.Load()-finished,
+		"abandoned", a.stats.inFlight.Load(),
+	)
+	stopReports()
+	if cfg.reportInterval > 0 {
+		rp.summary()
+	}
+	if err != nil {
+		slog.Error("shutdown error", "error", err)
+		return
+	}
+	slog.Info("server stopped")
+}
+
+// removeStaleSocket removes a socket file left behind by a previous run so
+// the path can be bound again. Sockets that still accept connections and
+// other kinds of files are left alone and make the listen fail.
+func removeStaleSocket(path string) {
+	fi, err := os.Lstat(path)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return
+	}
+	if c, err := net.Dial("unix", path); err == nil {
+		_ = c.Close()
+		return
+	}
+	_ = os.Remove(path)
+}
+
+// exitAddrInUse is the exit status used when a listen address is taken.
+const exitAddrInUse = 3
 
-	log.Printf("listening on %s", server.Addr)
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("server error: %v", err)
+// fatalListen reports a failure to bind addr and exits. An address already in
+// use gets a hint naming addrFlag and its own exit status.
+func fatalListen(err error, addr, addrFlag string) {
+	if errors.Is(err, syscall.EADDRINUSE) {
+		slog.Error("address already in use; is another instance running? choose a different address with "+addrFlag,
+			"addr", addr, "error", err)
+		os.Exit(exitAddrInUse)
 	}
+	fatal("listen failed", "addr", addr, "error", err)
 }
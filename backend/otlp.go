@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// otlpSignal identifies which OTLP export service an endpoint implements.
+type otlpSignal int
+
+const (
+	signalTraces otlpSignal = iota
+	signalLogs
+	signalMetrics
+)
+
+var allSignals = []otlpSignal{signalTraces, signalLogs, signalMetrics}
+
+// String returns the signal name as used in OTLP/HTTP paths.
+func (s otlpSignal) String() string {
+	switch s {
+	case signalTraces:
+		return "traces"
+	case signalLogs:
+		return "logs"
+	case signalMetrics:
+		return "metrics"
+	}
+	return "unknown"
+}
+
+// itemsKey returns the log key used to report the number of items in a request.
+func (s otlpSignal) itemsKey() string {
+	switch s {
+	case signalTraces:
+		return "spans"
+	case signalLogs:
+		return "log_records"
+	case signalMetrics:
+		return "data_points"
+	}
+	return "items"
+}
+
+const (
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+)
+
+// decodeRequest decodes an OTLP export request body of the given media type.
+// It returns a nil message when the media type is not one we know how to
+// decode.
+func decodeRequest(sig otlpSignal, mediaType string, body []byte) (proto.Message, error) {
+	var unmarshal func([]byte, proto.Message) error
+	switch mediaType {
+	case contentTypeProtobuf:
+		unmarshal = proto.Unmarshal
+	case contentTypeJSON:
+		// OTLP/JSON encodes trace and span IDs as hex rather than base64,
+		// which protojson still accepts; the IDs come out garbled but the
+		// item counts are unaffected.
+		unmarshal = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal
+	default:
+		return nil, nil
+	}
+
+	msg := newExportRequest(sig)
+	if err := unmarshal(body, msg); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", sig, err)
+	}
+	return msg, nil
+}
+
+// newExportRequest returns an empty export request message for the signal.
+func newExportRequest(sig otlpSignal) proto.Message {
+	switch sig {
+	case signalTraces:
+		return new(coltracepb.ExportTraceServiceRequest)
+	case signalLogs:
+		return new(collogspb.ExportLogsServiceRequest)
+	default:
+		return new(colmetricspb.ExportMetricsServiceRequest)
+	}
+}
+
+// newExportResponse returns an empty export response message for the signal,
+// acknowledging every item.
+func newExportResponse(sig otlpSignal) proto.Message {
+	switch sig {
+	case signalTraces:
+		return new(coltracepb.ExportTraceServiceResponse)
+	case signalLogs:
+		return new(collogspb.ExportLogsServiceResponse)
+	default:
+		return new(colmetricspb.ExportMetricsServiceResponse)
+	}
+}
+
+// newPartialSuccessResponse returns an export response acknowledging the
+// request while reporting rejected items as rejected with msg.
+func newPartialSuccessResponse(sig otlpSignal, rejected int64, msg string) proto.Message {
+	switch sig {
+	case signalTraces:
+		return &coltracepb.ExportTraceServiceResponse{
+			PartialSuccess: &coltracepb.ExportTracePartialSuccess{
+				RejectedSpans: rejected,
+				ErrorMessage:  msg,
+			},
+		}
+	case signalLogs:
+		return &collogspb.ExportLogsServiceResponse{
+			PartialSuccess: &collogspb.ExportLogsPartialSuccess{
+				RejectedLogRecords: rejected,
+				ErrorMessage:       msg,
+			},
+		}
+	default:
+		return &colmetricspb.ExportMetricsServiceResponse{
+			PartialSuccess: &colmetricspb.ExportMetricsPartialSuccess{
+				RejectedDataPoints: rejected,
+				ErrorMessage:       msg,
+			},
+		}
+	}
+}
+
+// countMessage returns the number of items in a decoded export request.
+func countMessage(msg proto.Message) int {
+	var n int
+	switch req := msg.(type) {
+	case *coltracepb.ExportTraceServiceRequest:
+		for _, rs := range req.GetResourceSpans() {
+			for _, ss := range rs.GetScopeSpans() {
+				n += len(ss.GetSpans())
+			}
+		}
+	case *collogspb.ExportLogsServiceRequest:
+		for _, rl := range req.GetResourceLogs() {
+			for _, sl := range rl.GetScopeLogs() {
+				n += len(sl.GetLogRecords())
+			}
+		}
+	case *colmetricspb.ExportMetricsServiceRequest:
+		for _, rm := range req.GetResourceMetrics() {
+			for _, sm := range rm.GetScopeMetrics() {
+				for _, m := range sm.GetMetrics() {
+					n += countDataPoints(m)
+				}
+			}
+		}
+	}
+	return n
+}
+
+func countDataPoints(m *metricspb.Metric) int {
+	switch data := m.GetData().(type) {
+	case *metricspb.Metric_Gauge:
+		return len(data.Gauge.GetDataPoints())
+	case *metricspb.Metric_Sum:
+		return len(data.Sum.GetDataPoints())
+	case *metricspb.Metric_Histogram:
+		return len(data.Histogram.GetDataPoints())
+	case *metricspb.Metric_ExponentialHistogram:
+		return len(data.ExponentialHistogram.GetDataPoints())
+	case *metricspb.Metric_Summary:
+		return len(data.Summary.GetDataPoints())
+	}
+	return 0
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// promMetrics holds the Prometheus collectors describing the mock's own
+// ingest traffic.
+type promMetrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	bodySize *prometheus.HistogramVec
+	duration *prometheus.HistogramVec
+}
+
+func newPromMetrics() *promMetrics {
+	m := &promMetrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "otlp_mock_requests_total",
+			Help: "Number of requests received by OTLP endpoints.",
+		}, []string{"path", "method"}),
+		bodySize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "otlp_mock_request_body_bytes",
+			Help:    "Size of decoded OTLP request bodies.",
+			Buckets: prometheus.ExponentialBuckets(256, 4, 8),
+		}, []string{"path"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "otlp_mock_request_duration_seconds",
+			Help:    "Time spent handling OTLP requests.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"path", "method"}),
+	}
+	m.registry.MustRegister(
+		m.requests,
+		m.bodySize,
+		m.duration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// observeRequest records a handled request; path is the registered endpoint
+// path and unexpected methods are folded into "other", so label cardinality
+// stays bounded.
+func (m *promMetrics) observeRequest(path, method string, d time.Duration) {
+	method = methodLabel(method)
+	m.requests.WithLabelValues(path, method).Inc()
+	m.duration.WithLabelValues(path, method).Observe(d.Seconds())
+}
+
+// methodLabel returns method if it is one the OTLP endpoints expect, and
+// "other" otherwise.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodPost, http.MethodHead, http.MethodOptions, http.MethodGet:
+		return method
+	}
+	return "other"
+}
+
+func (m *promMetrics) observeBodySize(path string, size int) {
+	m.bodySize.WithLabelValues(path).Observe(float64(size))
+}
+
+func (m *promMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
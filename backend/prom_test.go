@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRequestMetricsFoldUnknownMethods(t *testing.T) {
+	a, srv := newTestApp(t, &config{})
+	for _, method := range []string{"FOO", "BAR", http.MethodPost, http.MethodPut} {
+		req, err := http.NewRequest(method, srv.URL+"/v1/traces", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", contentTypeJSON)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	families, err := a.prom.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]float64)
+	for _, mf := range families {
+		if mf.GetName() != "otlp_mock_requests_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "method" {
+					got[l.GetValue()] += m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	want := map[string]float64{http.MethodPost: 1, "other": 3}
+	if len(got) != len(want) || got[http.MethodPost] != 1 || got["other"] != 3 {
+		t.Errorf("got method labels %v, want %v", got, want)
+	}
+}
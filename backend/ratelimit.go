@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a per-client token bucket limiter.
+type rateLimiter struct {
+	rate  float64 // tokens added per second
+	burst float64 // bucket capacity
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a limiter allowing rate requests per second per
+// client with bursts of up to burst requests. Idle buckets are evicted in the
+// background.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	l := &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+	}
+	go l.evictLoop(l.idleTimeout())
+	return l
+}
+
+// idleTimeout is how long a bucket may go unused before it is evicted. By then
+// it has refilled completely, so evicting it does not change behavior.
+func (l *rateLimiter) idleTimeout() time.Duration {
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	return max(refill, time.Minute)
+}
+
+// allow takes a token from the bucket for key. When the bucket is empty it
+// returns false and how long until the next token is available.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := (1 - b.tokens) / l.rate
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+func (l *rateLimiter) evictLoop(idle time.Duration) {
+	ticker := time.NewTicker(idle)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		l.mu.Lock()
+		for key, b := range l.buckets {
+			if now.Sub(b.last) > idle {
+				delete(l.buckets, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+// clientIP returns the host part of a request's remote address.
+func clientIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// recentRequest describes a single OTLP request kept in the recent buffer.
+type recentRequest struct {
+	Time        time.Time `json:"time"`
+	RequestID   string    `json:"request_id"`
+	Path        string    `json:"path"`
+	Signal      string    `json:"signal"`
+	Tenant      string    `json:"tenant"`
+	SizeBytes   int       `json:"size_bytes"`
+	ContentType string    `json:"content_type"`
+	Items       *int      `json:"items,omitempty"`
+	Status      int       `json:"status"`
+}
+
+// recentBuffer is a fixed-size ring buffer of the most recent requests.
+// Writers claim a slot with a single atomic increment and publish the entry
+// with an atomic pointer store, so adding never takes a lock.
+type recentBuffer struct {
+	seq   atomic.Uint64
+	slots []atomic.Pointer[recentRequest]
+}
+
+func newRecentBuffer(size int) *recentBuffer {
+	return &recentBuffer{slots: make([]atomic.Pointer[recentRequest], size)}
+}
+
+func (b *recentBuffer) add(req *recentRequest) {
+	i := b.seq.Add(1) - 1
+	b.slots[i%uint64(len(b.slots))].Store(req)
+}
+
+// reset discards all entries.
+func (b *recentBuffer) reset() {
+	b.seq.Store(0)
+	for i := range b.slots {
+		b.slots[i].Store(nil)
+	}
+}
+
+// last returns up to n of the most recent entries, newest first.
+func (b *recentBuffer) last(n int) []*recentRequest {
+	seq := b.seq.Load()
+	n = min(n, len(b.slots), int(min(seq, uint64(len(b.slots)))))
+
+	out := make([]*recentRequest, 0, n)
+	for i := range uint64(n) {
+		if req := b.slots[(seq-1-i)%uint64(len(b.slots))].Load(); req != nil {
+			out = append(out, req)
+		}
+	}
+	return out
+}
+
+// ServeHTTP writes the last n entries as JSON, where n comes from the "n"
+// query parameter and defaults to 50.
+func (b *recentBuffer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := 50
+	if s := r.URL.Query().Get("n"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 0 {
+			writeJSONError(w, http.StatusBadRequest, "n must be a non-negative integer")
+			return
+		}
+		n = v
+	}
+	writeJSON(w, http.StatusOK, b.last(n))
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// faultSettings are the fault injection knobs that can be changed at runtime
+// by reloading the -reload-config file.
+type faultSettings struct {
+	latency   durationRange
+	errorRate float64
+	dropRate  float64
+}
+
+func (f *faultSettings) validate() error {
+	if f.errorRate < 0 || f.errorRate > 1 {
+		return fmt.Errorf("-error-rate must be between 0 and 1, got %g", f.errorRate)
+	}
+	if f.dropRate < 0 || f.dropRate > 1 {
+		return fmt.Errorf("-drop-rate must be between 0 and 1, got %g", f.dropRate)
+	}
+	return nil
+}
+
+// loadFaultSettings reads fault settings from path, starting from cur. The
+// file holds one flag per line without the leading dash, e.g.
+// "latency=100ms-200ms" or "error-rate=0.1"; blank lines and lines starting
+// with # are ignored, and settings not mentioned keep their current value.
+func loadFaultSettings(path string, cur faultSettings) (*faultSettings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	next := cur
+	fs := flag.NewFlagSet(path, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Var(&next.latency, "latency", "")
+	fs.Float64Var(&next.errorRate, "error-rate", next.errorRate, "")
+	fs.Float64Var(&next.dropRate, "drop-rate", next.dropRate, "")
+
+	var args []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			args = append(args, "-"+line)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := next.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &next, nil
+}
+
+// reloadFaults re-reads the -reload-config file and swaps in the new fault
+// settings, logging each value that changed. Invalid files leave the current
+// settings in place.
+func (a *app) reloadFaults() {
+	cur := a.faults.Load()
+	next, err := loadFaultSettings(a.cfg.reloadConfig, *cur)
+	if err != nil {
+		slog.Error("config reload failed", "error", err)
+		return
+	}
+	a.faults.Store(next)
+
+	var changes []any
+	if next.latency != cur.latency {
+		changes = append(changes, "latency", cur.latency.String()+" -> "+next.latency.String())
+	}
+	if next.errorRate != cur.errorRate {
+		changes = append(changes, "error_rate", fmt.Sprintf("%g -> %g", cur.errorRate, next.errorRate))
+	}
+	if next.dropRate != cur.dropRate {
+		changes = append(changes, "drop_rate", fmt.Sprintf("%g -> %g", cur.dropRate, next.dropRate))
+	}
+	if len(changes) == 0 {
+		slog.Info("config reloaded, nothing changed", "file", a.cfg.reloadConfig)
+		return
+	}
+	slog.Info("config reloaded", append([]any{"file", a.cfg.reloadConfig}, changes...)...)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// runReplay re-sends the payloads captured with -dump-dir in cfg.replayDir to
+// cfg.target, in the order they were received, then prints a summary.
+func runReplay(cfg *config) error {
+	files, err := readDumpDir(cfg.replayDir)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no captured payloads found in %s", cfg.replayDir)
+	}
+	base, err := replayBase(cfg.target)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client := newLoadgenClient(cfg)
+	res := &loadgenResults{statuses: make(map[int]int64)}
+	tokens := pace(ctx, cfg.sendRate)
+
+	fmt.Printf("replay: dir=%s files=%d target=%s concurrency=%d rate=%g\n",
+		cfg.replayDir, len(files), base, cfg.concurrency, cfg.sendRate)
+
+	queue := make(chan dumpFile)
+	go func() {
+		defer close(queue)
+		for _, f := range files {
+			if tokens != nil {
+				if _, ok := <-tokens; !ok {
+					return
+				}
+			}
+			select {
+			case queue <- f:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range cfg.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range queue {
+				replayFile(ctx, client, base, cfg.authToken, f, res)
+			}
+		}()
+	}
+	wg.Wait()
+
+	printReplaySummary(res, len(files), time.Since(start))
+	return nil
+}
+
+// readDumpDir returns the dump files in dir sorted by receive time, with the
+// exact content type of those that have a contentTypeSuffix file. Files not
+// named by the dumper are skipped.
+func readDumpDir(dir string) ([]dumpFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []dumpFile
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasSuffix(e.Name(), contentTypeSuffix) {
+			continue
+		}
+		f, ok := parseDumpName(e.Name())
+		if !ok {
+			slog.Warn("skipping unrecognized file", "name", e.Name())
+			continue
+		}
+		f.path = filepath.Join(dir, e.Name())
+		if typ, err := os.ReadFile(f.path + contentTypeSuffix); err == nil {
+			f.contentType = string(typ)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	slices.SortFunc(files, func(a, b dumpFile) int {
+		return cmp.Or(cmp.Compare(a.received, b.received), strings.Compare(a.path, b.path))
+	})
+	return files, nil
+}
+
+// replayBase validates target and returns it without a trailing slash, so
+// the signal path can be appended, e.g. http://host:4318/otlp.
+func replayBase(target string) (string, error) {
+	if target == "" {
+		return "", fmt.Errorf("-target is required")
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("invalid -target: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid -target %q: scheme must be http or https", target)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return u.String(), nil
+}
+
+func replayFile(ctx context.Context, client *http.Client, base, token string, f dumpFile, res *loadgenResults) {
+	body, err := os.ReadFile(f.path)
+	if err != nil {
+		slog.Warn("read capture failed", "path", f.path, "error", err)
+		res.netErrs.Add(1)
+		return
+	}
+	header := http.Header{"Content-Type": {f.contentType}}
+	if f.contentEncoding != "" {
+		header.Set("Content-Encoding", f.contentEncoding)
+	}
+	postExport(ctx, client, base+"/v1/"+f.signal.String(), token, header, body, res)
+}
+
+func printReplaySummary(res *loadgenResults, files int, elapsed time.Duration) {
+	secs := elapsed.Seconds()
+	sent := res.sent.Load()
+	q := res.latency.quantiles(0.5, 0.9, 0.99)
+
+	fmt.Printf("elapsed:       %s\n", elapsed.Round(time.Millisecond))
+	fmt.Printf("files:         %d\n", files)
+	fmt.Printf("replayed:      %d (%.1f/s)\n", sent, float64(sent)/secs)
+	fmt.Printf("succeeded:     %d\n", res.succeeded.Load())
+	fmt.Printf("failed:        %d (http %d, net %d)\n",
+		res.httpErrs.Load()+res.netErrs.Load(), res.httpErrs.Load(), res.netErrs.Load())
+	fmt.Printf("bytes:         %d (%.1f KiB/s)\n", res.bytes.Load(), float64(res.bytes.Load())/1024/secs)
+	fmt.Printf("latency:       p50=%s p90=%s p99=%s\n", q[0], q[1], q[2])
+	printStatuses(res)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// reporter periodically logs aggregate throughput computed from stats.
+type reporter struct {
+	stats *stats
+	start time.Time
+
+	lastTime     time.Time
+	lastRequests int64
+	lastBytes    int64
+}
+
+func newReporter(st *stats) *reporter {
+	now := time.Now()
+	return &reporter{stats: st, start: now, lastTime: now}
+}
+
+// run logs a report every interval until ctx is done.
+func (rp *reporter) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			rp.report(now)
+		}
+	}
+}
+
+func (rp *reporter) report(now time.Time) {
+	requests, bytes := rp.stats.totals()
+	deltaRequests := requests - rp.lastRequests
+	deltaBytes := bytes - rp.lastBytes
+	if deltaRequests < 0 {
+		// The counters were reset via /admin/reset since the last tick.
+		deltaRequests, deltaBytes = requests, bytes
+	}
+	elapsed := now.Sub(rp.lastTime).Seconds()
+
+	slog.Info("throughput",
+		"requests", requests,
+		"bytes", bytes,
+		"delta_requests", deltaRequests,
+		"delta_bytes", deltaBytes,
+		"rps", rate(deltaRequests, elapsed),
+	)
+
+	rp.lastTime, rp.lastRequests, rp.lastBytes = now, requests, bytes
+}
+
+// summary logs the totals since the reporter was created.
+func (rp *reporter) summary() {
+	requests, bytes := rp.stats.totals()
+	uptime := time.Since(rp.start)
+	slog.Info("summary",
+		"requests", requests,
+		"bytes", bytes,
+		"uptime", uptime.Round(time.Millisecond).String(),
+		"avg_rps", rate(requests, uptime.Seconds()),
+	)
+}
+
+// rate returns n per second over secs, rounded to one decimal place.
+func rate(n int64, secs float64) float64 {
+	if secs <= 0 {
+		return 0
+	}
+	return math.Round(float64(n)/secs*10) / 10
+}
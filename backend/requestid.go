@@ -0,0 +1,37 @@
+package main
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"math/rand/v2"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// newRequestID returns a random version 4 UUID. When fast is set it draws from
+// math/rand instead of crypto/rand, which is cheaper under heavy load but not
+// suitable where IDs must be unpredictable.
+func newRequestID(fast bool) string {
+	var b [16]byte
+	if fast {
+		binary.LittleEndian.PutUint64(b[:8], rand.Uint64())
+		binary.LittleEndian.PutUint64(b[8:], rand.Uint64())
+	} else {
+		_, _ = crand.Read(b[:])
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+
+	var s [36]byte
+	hex.Encode(s[0:8], b[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], b[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], b[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], b[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], b[10:])
+	return string(s[:])
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// writeJSON writes v as a JSON body with the given status code.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONError writes a short JSON error body with the given status code.
+func writeJSONError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
+
+// setRetryAfter sets the Retry-After header to d rounded up to whole seconds.
+func setRetryAfter(w http.ResponseWriter, d time.Duration) {
+	secs := int64((d + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+}
+
+// writeBody writes body with the given status code and content type. When the
+// client accepts gzip and body is at least minGzip bytes long, the body is
+// sent gzip-compressed; minGzip <= 0 disables compression.
+func writeBody(w http.ResponseWriter, r *http.Request, code int, contentType string, body []byte, minGzip int64) {
+	h := w.Header()
+	h.Set("Content-Type", contentType)
+	if minGzip <= 0 || int64(len(body)) < minGzip {
+		w.WriteHeader(code)
+		_, _ = w.Write(body)
+		return
+	}
+
+	h.Add("Vary", "Accept-Encoding")
+	if !acceptsGzip(r) {
+		w.WriteHeader(code)
+		_, _ = w.Write(body)
+		return
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write(body)
+	_ = zw.Close()
+	h.Set("Content-Encoding", "gzip")
+	h.Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(code)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, v := range r.Header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(v, ",") {
+			coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding != "gzip" && coding != "x-gzip" && coding != "*" {
+				continue
+			}
+			if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				if f, err := strconv.ParseFloat(q, 64); err == nil && f == 0 {
+					continue
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// writeExportResponse writes an OTLP export response message encoded as
+// protobuf or as OTLP/JSON.
+func writeExportResponse(w http.ResponseWriter, r *http.Request, msg proto.Message, protobuf bool, minGzip int64) {
+	contentType, marshal := contentTypeJSON, protojson.Marshal
+	if protobuf {
+		contentType, marshal = contentTypeProtobuf, proto.Marshal
+	}
+	body, err := marshal(msg)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeBody(w, r, http.StatusOK, contentType, body, minGzip)
+}
+
+// acceptsProtobuf reports whether the request's Accept header prefers an
+// OTLP/protobuf response. Protobuf must be listed explicitly and rank above
+// JSON, so wildcards and ties keep the JSON default.
+func acceptsProtobuf(r *http.Request) bool {
+	var qProtobuf, qJSON float64
+	for _, v := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(v, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if s, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(s, 64); err != nil {
+					continue
+				}
+			}
+			switch mediaType {
+			case contentTypeProtobuf, "application/protobuf":
+				qProtobuf = max(qProtobuf, q)
+			case contentTypeJSON:
+				qJSON = max(qJSON, q)
+			}
+		}
+	}
+	return qProtobuf > 0 && qProtobuf > qJSON
+}
+
+// paddedResponse returns an OTLP/JSON export response of exactly n bytes. The
+// padding is carried in a "padding" field, which OTLP clients ignore as
+// unknown, so the body still parses as an empty response. Bodies too short
+// for the field are padded with trailing whitespace instead.
+func paddedResponse(n int) []byte {
+	const prefix, suffix = `{"padding":"`, `"}`
+	if n < len(prefix)+len(suffix) {
+		return append([]byte("{}"), bytes.Repeat([]byte(" "), max(n-2, 0))...)
+	}
+	b := make([]byte, 0, n)
+	b = append(b, prefix...)
+	b = append(b, bytes.Repeat([]byte("x"), n-len(prefix)-len(suffix))...)
+	return append(b, suffix...)
+}
+
+// paddingField is the protobuf field number carrying response padding. It is
+// far above any field OTLP defines, so clients skip it as unknown.
+const paddingField protowire.Number = 1 << 20
+
+// paddedProtobufResponse is the OTLP/protobuf counterpart of paddedResponse:
+// an empty export response made exactly n bytes long by unknown
+// length-delimited fields. Where no single field fits exactly, empty fields
+// make up the difference. Sizes too small for any field send fewer bytes.
+func paddedProtobufResponse(n int) []byte {
+	tag := protowire.SizeTag(paddingField)
+	b := make([]byte, 0, n)
+	for n > tag {
+		l := n - tag - 1
+		for l > 0 && tag+protowire.SizeVarint(uint64(l))+l > n {
+			l--
+		}
+		if tag+protowire.SizeVarint(uint64(l))+l == n {
+			b = protowire.AppendTag(b, paddingField, protowire.BytesType)
+			b = protowire.AppendVarint(b, uint64(l))
+			return append(b, bytes.Repeat([]byte("x"), l)...)
+		}
+		b = protowire.AppendTag(b, paddingField, protowire.BytesType)
+		b = protowire.AppendVarint(b, 0)
+		n -= tag + 1
+	}
+	return b
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// schemaLimit bounds the number of distinct schema URLs and instrumentation
+// scopes tallied.
+const schemaLimit = 1000
+
+// observeSchemas tallies the resource and scope schema URLs and the
+// instrumentation scopes of a decoded export request. Scopes are tallied as
+// "name@version", or just the name when the version is empty.
+func (s *stats) observeSchemas(msg proto.Message) {
+	addURL := func(url string) {
+		if url != "" {
+			s.schemaURLs.add(url)
+		}
+	}
+	addScope := func(scope *commonpb.InstrumentationScope, schemaURL string) {
+		addURL(schemaURL)
+		name := scope.GetName()
+		if v := scope.GetVersion(); v != "" {
+			name += "@" + v
+		}
+		s.scopes.add(name)
+	}
+
+	switch req := msg.(type) {
+	case *coltracepb.ExportTraceServiceRequest:
+		for _, rs := range req.GetResourceSpans() {
+			addURL(rs.GetSchemaUrl())
+			for _, ss := range rs.GetScopeSpans() {
+				addScope(ss.GetScope(), ss.GetSchemaUrl())
+			}
+		}
+	case *collogspb.ExportLogsServiceRequest:
+		for _, rl := range req.GetResourceLogs() {
+			addURL(rl.GetSchemaUrl())
+			for _, sl := range rl.GetScopeLogs() {
+				addScope(sl.GetScope(), sl.GetSchemaUrl())
+			}
+		}
+	case *colmetricspb.ExportMetricsServiceRequest:
+		for _, rm := range req.GetResourceMetrics() {
+			addURL(rm.GetSchemaUrl())
+			for _, sm := range rm.GetScopeMetrics() {
+				addScope(sm.GetScope(), sm.GetSchemaUrl())
+			}
+		}
+	}
+}
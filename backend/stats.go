@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// endpointStats holds the counters for a single OTLP endpoint. All fields are
+// updated atomically so the ingest handlers never contend on a lock.
+type endpointStats struct {
+	requests atomic.Int64
+	bytes    atomic.Int64
+	latency  latencyHistogram
+}
+
+func (e *endpointStats) record(size int) {
+	e.requests.Add(1)
+	e.bytes.Add(int64(size))
+}
+
+// recordDuration records how long the handler took to serve a request.
+func (e *endpointStats) recordDuration(d time.Duration) {
+	e.latency.record(d)
+}
+
+func (e *endpointStats) snapshot(reset bool) endpointSnapshot {
+	es := endpointSnapshot{
+		Requests: loadCounter(&e.requests, reset),
+		Bytes:    loadCounter(&e.bytes, reset),
+	}
+	if es.Requests > 0 {
+		es.AvgBodyBytes = float64(es.Bytes) / float64(es.Requests)
+	}
+	counts := e.latency.load(reset)
+	q := histQuantiles(&counts, 0.5, 0.9, 0.99)
+	es.LatencyMs = latencySnapshot{P50: durationMs(q[0]), P90: durationMs(q[1]), P99: durationMs(q[2])}
+	return es
+}
+
+// stats aggregates per-endpoint counters. Endpoints are registered before the
+// server starts, so the map itself is never written concurrently.
+type stats struct {
+	endpoints map[string]*endpointStats
+	bySignal  map[otlpSignal]*endpointStats
+
+	// inFlight counts OTLP requests that are currently being handled and
+	// finished counts those that have completed since startup.
+	inFlight atomic.Int64
+	finished atomic.Int64
+	// overflows counts requests rejected by the concurrency limit.
+	overflows atomic.Int64
+	// duplicates counts request bodies already received within the
+	// duplicate detection window.
+	duplicates atomic.Int64
+
+	// userAgents tallies the User-Agent headers of OTLP requests.
+	userAgents *tally
+	// schemaURLs and scopes tally the schema URLs and instrumentation scopes
+	// found in decoded payloads.
+	schemaURLs *tally
+	scopes     *tally
+
+	// attrs tallies tracked resource attribute values, or is nil when no
+	// attributes are tracked.
+	attrs *attrTracker
+}
+
+// userAgentLimit bounds the number of distinct User-Agent values tallied.
+const userAgentLimit = 1000
+
+func newStats() *stats {
+	return &stats{
+		endpoints:  make(map[string]*endpointStats),
+		bySignal:   make(map[otlpSignal]*endpointStats),
+		userAgents: newTally(userAgentLimit),
+		schemaURLs: newTally(schemaLimit),
+		scopes:     newTally(schemaLimit),
+	}
+}
+
+// endpoint registers path as the endpoint for sig and returns its counters.
+func (s *stats) endpoint(path string, sig otlpSignal) *endpointStats {
+	e := new(endpointStats)
+	s.endpoints[path] = e
+	s.bySignal[sig] = e
+	return e
+}
+
+// signal returns the counters of the endpoint registered for sig, or nil.
+func (s *stats) signal(sig otlpSignal) *endpointStats {
+	return s.bySignal[sig]
+}
+
+// observe records the resource attributes, schema URLs, and scopes of a
+// decoded export request.
+func (s *stats) observe(msg proto.Message) {
+	if s.attrs != nil {
+		s.attrs.observe(msg)
+	}
+	s.observeSchemas(msg)
+}
+
+// begin marks the start of an OTLP request; done must be called when it
+// completes.
+func (s *stats) begin() {
+	s.inFlight.Add(1)
+}
+
+func (s *stats) done() {
+	s.inFlight.Add(-1)
+	s.finished.Add(1)
+}
+
+// waitIdle waits until no OTLP request is in flight or ctx is done. It also
+// covers handlers that server.Shutdown does not track, such as WebSocket
+// connections.
+func (s *stats) waitIdle(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for s.inFlight.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// totals returns the request and byte counts summed over all endpoints. It
+// only loads atomics, so it never blocks the ingest handlers.
+func (s *stats) totals() (requests, bytes int64) {
+	for _, e := range s.endpoints {
+		requests += e.requests.Load()
+		bytes += e.bytes.Load()
+	}
+	return requests, bytes
+}
+
+type endpointSnapshot struct {
+	Requests     int64           `json:"requests"`
+	Bytes        int64           `json:"bytes"`
+	AvgBodyBytes float64         `json:"avg_body_bytes"`
+	LatencyMs    latencySnapshot `json:"latency_ms"`
+}
+
+type latencySnapshot struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+}
+
+type statsSnapshot struct {
+	Endpoints  map[string]endpointSnapshot `json:"endpoints"`
+	InFlight   int64                       `json:"in_flight"`
+	Overflows  int64                       `json:"overflows"`
+	Duplicates int64                       `json:"duplicate_count"`
+	UserAgents tallySnapshot               `json:"user_agents"`
+	SchemaURLs tallySnapshot               `json:"schema_urls"`
+	Scopes     tallySnapshot               `json:"scopes"`
+
+	ResourceAttributes map[string]attrSnapshot `json:"resource_attributes,omitempty"`
+}
+
+func (s *stats) snapshot() statsSnapshot {
+	return s.load(false)
+}
+
+// reset zeroes all counters and returns their values from just before. Each
+// counter is swapped atomically, so no request is lost or counted twice.
+func (s *stats) reset() statsSnapshot {
+	return s.load(true)
+}
+
+func (s *stats) load(reset bool) statsSnapshot {
+	snap := statsSnapshot{
+		Endpoints:  make(map[string]endpointSnapshot, len(s.endpoints)),
+		InFlight:   s.inFlight.Load(),
+		Overflows:  loadCounter(&s.overflows, reset),
+		Duplicates: loadCounter(&s.duplicates, reset),
+		UserAgents: s.userAgents.snapshot(reset),
+		SchemaURLs: s.schemaURLs.snapshot(reset),
+		Scopes:     s.scopes.snapshot(reset),
+	}
+	for path, e := range s.endpoints {
+		snap.Endpoints[path] = e.snapshot(reset)
+	}
+	if s.attrs != nil {
+		snap.ResourceAttributes = s.attrs.load(reset)
+	}
+	return snap
+}
+
+// loadCounter returns the value of v, zeroing it when reset is set.
+func loadCounter(v *atomic.Int64, reset bool) int64 {
+	if reset {
+		return v.Swap(0)
+	}
+	return v.Load()
+}
+
+// ServeHTTP writes the current snapshot as JSON.
+func (s *stats) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.snapshot())
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+)
+
+// tallyTopValues is the number of most frequent values reported by a tally.
+const tallyTopValues = 10
+
+// tally counts occurrences of distinct string values. At most limit distinct
+// values are kept; occurrences of further new values are counted as overflow
+// so memory stays bounded.
+type tally struct {
+	limit int
+
+	mu       sync.Mutex
+	counts   map[string]int64
+	overflow int64
+}
+
+func newTally(limit int) *tally {
+	return &tally{limit: limit, counts: make(map[string]int64)}
+}
+
+func (t *tally) add(v string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.counts[v] > 0 || len(t.counts) < t.limit {
+		t.counts[v]++
+	} else {
+		t.overflow++
+	}
+}
+
+type tallySnapshot struct {
+	Distinct int          `json:"distinct"`
+	Overflow int64        `json:"overflow"`
+	Top      []tallyCount `json:"top"`
+}
+
+type tallyCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// snapshot returns the distinct count and the most frequent values,
+// clearing the tally when reset is set.
+func (t *tally) snapshot(reset bool) tallySnapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	top := make([]tallyCount, 0, len(t.counts))
+	for v, n := range t.counts {
+		top = append(top, tallyCount{Value: v, Count: n})
+	}
+	slices.SortFunc(top, func(a, b tallyCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Value, b.Value))
+	})
+	snap := tallySnapshot{
+		Distinct: len(t.counts),
+		Overflow: t.overflow,
+		Top:      top[:min(len(top), tallyTopValues)],
+	}
+	if reset {
+		clear(t.counts)
+		t.overflow = 0
+	}
+	return snap
+}
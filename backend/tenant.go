@@ -0,0 +1,149 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	tenantHeader = "X-Tenant"
+
+	// defaultTenant receives requests without an X-Tenant header.
+	defaultTenant = "default"
+	// overflowTenant receives requests for new tenants once -max-tenants
+	// named tenants exist.
+	overflowTenant = "_overflow"
+)
+
+// tenant holds the counters and recent requests of one logical collector.
+type tenant struct {
+	stats  *stats
+	recent *recentBuffer
+}
+
+type tenantEndpoint struct {
+	path string
+	sig  otlpSignal
+}
+
+// tenantRegistry partitions OTLP requests by their X-Tenant header. Tenants
+// are created on first use, up to limit of them besides the default one.
+type tenantRegistry struct {
+	limit      int
+	recentSize int
+	endpoints  []tenantEndpoint
+	attrKeys   []string
+	attrLimit  int
+
+	mu      sync.RWMutex
+	tenants map[string]*tenant
+	named   int // tenants other than the default and overflow ones
+}
+
+func newTenantRegistry(limit, recentSize int) *tenantRegistry {
+	return &tenantRegistry{
+		limit:      limit,
+		recentSize: recentSize,
+		tenants:    make(map[string]*tenant),
+	}
+}
+
+// addEndpoint registers an OTLP endpoint that every tenant keeps counters
+// for. It must be called before any request is served.
+func (tr *tenantRegistry) addEndpoint(path string, sig otlpSignal) {
+	tr.endpoints = append(tr.endpoints, tenantEndpoint{path: path, sig: sig})
+}
+
+// trackAttrs makes every tenant tally the given resource attributes, like
+// the totals do. It must be called before any request is served.
+func (tr *tenantRegistry) trackAttrs(keys []string, limit int) {
+	tr.attrKeys = keys
+	tr.attrLimit = limit
+}
+
+// get returns the tenant named by the X-Tenant header value, creating it if
+// needed.
+func (tr *tenantRegistry) get(name string) (string, *tenant) {
+	if name = strings.TrimSpace(name); name == "" {
+		name = defaultTenant
+	}
+	tr.mu.RLock()
+	t, ok := tr.tenants[name]
+	tr.mu.RUnlock()
+	if ok {
+		return name, t
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if t, ok := tr.tenants[name]; ok {
+		return name, t
+	}
+	if name != defaultTenant {
+		if tr.named >= tr.limit {
+			name = overflowTenant
+			if t, ok := tr.tenants[name]; ok {
+				return name, t
+			}
+		} else {
+			tr.named++
+		}
+	}
+	t = &tenant{stats: newStats(), recent: newRecentBuffer(tr.recentSize)}
+	for _, e := range tr.endpoints {
+		t.stats.endpoint(e.path, e.sig)
+	}
+	if len(tr.attrKeys) > 0 {
+		t.stats.attrs = newAttrTracker(tr.attrKeys, tr.attrLimit)
+	}
+	tr.tenants[name] = t
+	return name, t
+}
+
+// lookup returns an existing tenant.
+func (tr *tenantRegistry) lookup(name string) (*tenant, bool) {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	t, ok := tr.tenants[name]
+	return t, ok
+}
+
+// reset forgets all tenants.
+func (tr *tenantRegistry) reset() {
+	tr.mu.Lock()
+	clear(tr.tenants)
+	tr.named = 0
+	tr.mu.Unlock()
+}
+
+// handleStats serves the stats of the tenant named by the "tenant" query
+// parameter, or the totals over all tenants when it is absent.
+func (a *app) handleStats(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("tenant")
+	if name == "" {
+		a.stats.ServeHTTP(w, r)
+		return
+	}
+	t, ok := a.tenants.lookup(name)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "unknown tenant "+name)
+		return
+	}
+	t.stats.ServeHTTP(w, r)
+}
+
+// handleRecent is the /recent counterpart of handleStats.
+func (a *app) handleRecent(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("tenant")
+	if name == "" {
+		a.recent.ServeHTTP(w, r)
+		return
+	}
+	t, ok := a.tenants.lookup(name)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "unknown tenant "+name)
+		return
+	}
+	t.recent.ServeHTTP(w, r)
+}
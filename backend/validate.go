@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// validateRequest checks a decoded export request for problems a strict
+// collector would reject. Trace and span ID lengths are only checked when
+// checkIDs is set, because OTLP/JSON hex IDs do not survive protojson
+// decoding intact.
+func validateRequest(msg proto.Message, checkIDs bool) error {
+	switch req := msg.(type) {
+	case *coltracepb.ExportTraceServiceRequest:
+		for i, rs := range req.GetResourceSpans() {
+			if rs.GetResource() == nil {
+				return fmt.Errorf("resource_spans[%d]: missing resource", i)
+			}
+			for j, ss := range rs.GetScopeSpans() {
+				for k, span := range ss.GetSpans() {
+					where := fmt.Sprintf("resource_spans[%d].scope_spans[%d].spans[%d]", i, j, k)
+					if span.GetName() == "" {
+						return fmt.Errorf("%s: missing name", where)
+					}
+					if !checkIDs {
+						continue
+					}
+					if n := len(span.GetTraceId()); n != 16 {
+						return fmt.Errorf("%s: trace_id must be 16 bytes, got %d", where, n)
+					}
+					if n := len(span.GetSpanId()); n != 8 {
+						return fmt.Errorf("%s: span_id must be 8 bytes, got %d", where, n)
+					}
+				}
+			}
+		}
+	case *collogspb.ExportLogsServiceRequest:
+		for i, rl := range req.GetResourceLogs() {
+			if rl.GetResource() == nil {
+				return fmt.Errorf("resource_logs[%d]: missing resource", i)
+			}
+		}
+	case *colmetricspb.ExportMetricsServiceRequest:
+		for i, rm := range req.GetResourceMetrics() {
+			if rm.GetResource() == nil {
+				return fmt.Errorf("resource_metrics[%d]: missing resource", i)
+			}
+			for j, sm := range rm.GetScopeMetrics() {
+				for k, m := range sm.GetMetrics() {
+					where := fmt.Sprintf("resource_metrics[%d].scope_metrics[%d].metrics[%d]", i, j, k)
+					if m.GetName() == "" {
+						return fmt.Errorf("%s: missing name", where)
+					}
+					if m.GetData() == nil {
+						return fmt.Errorf("%s: missing data", where)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
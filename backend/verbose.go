@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// connInfo tracks a client connection for -verbose request traces.
+type connInfo struct {
+	requests atomic.Int64
+}
+
+type connInfoKey struct{}
+
+// verboseConnContext attaches a connInfo to the context of every request
+// served on c.
+func verboseConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connInfoKey{}, new(connInfo))
+}
+
+// verboseConnState logs connection lifecycle transitions.
+func verboseConnState(c net.Conn, state http.ConnState) {
+	slog.Info("connection", "remote", c.RemoteAddr().String(), "state", state.String())
+}
+
+// verboseHandler logs the protocol, TLS, connection reuse, and header details
+// of every request before passing it to next.
+func verboseHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		args := []any{
+			"method", r.Method,
+			"path", r.URL.Path,
+			"proto", r.Proto,
+			"remote", r.RemoteAddr,
+		}
+		if ci, ok := r.Context().Value(connInfoKey{}).(*connInfo); ok {
+			n := ci.requests.Add(1)
+			args = append(args, "conn_request", n, "reused", n > 1)
+		}
+		if r.TLS != nil {
+			args = append(args,
+				"tls_version", tls.VersionName(r.TLS.Version),
+				"tls_cipher", tls.CipherSuiteName(r.TLS.CipherSuite),
+				"alpn", r.TLS.NegotiatedProtocol,
+				"tls_resumed", r.TLS.DidResume,
+			)
+		}
+		args = append(args, headerGroup(r.Header))
+		slog.Info("request trace", args...)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// headerGroup returns the headers as a sorted log group, masking
+// credentials.
+func headerGroup(h http.Header) slog.Attr {
+	attrs := make([]any, 0, len(h))
+	for _, k := range slices.Sorted(maps.Keys(h)) {
+		v := strings.Join(h[k], ", ")
+		if k == "Authorization" || k == "Cookie" {
+			v = "[redacted]"
+		}
+		attrs = append(attrs, slog.String(k, v))
+	}
+	return slog.Group("headers", attrs...)
+}
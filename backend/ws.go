@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/websocket"
+	"google.golang.org/protobuf/proto"
+)
+
+// registerWebSocket mounts an experimental OTLP-over-WebSocket endpoint for
+// sig at path. Each binary message carries one OTLP/protobuf export request
+// and is answered with a binary message holding the protobuf export
+// response; undecodable messages get a JSON error text message instead.
+// Messages are recorded under the counters of the signal's HTTP endpoint, so
+// it must be registered first.
+func (a *app) registerWebSocket(mux *http.ServeMux, path string, sig otlpSignal) {
+	cfg := a.cfg
+	es := a.stats.signal(sig)
+	ws := websocket.Server{
+		Handshake: func(c *websocket.Config, r *http.Request) error {
+			if origin := r.Header.Get("Origin"); !cfg.cors.allowsOrigin(origin) {
+				return errors.New("origin not allowed: " + origin)
+			}
+			return nil
+		},
+		Handler: func(conn *websocket.Conn) {
+			// The connection outlives the server's request timeouts.
+			_ = conn.SetDeadline(time.Time{})
+			conn.MaxPayloadBytes = int(cfg.maxBody)
+			a.serveWebSocket(conn, sig, es)
+		},
+	}
+	mux.HandleFunc("GET "+path, func(w http.ResponseWriter, r *http.Request) {
+		if !checkToken(r, cfg.authToken) {
+			slog.Warn("auth failed", "path", r.URL.Path, "remote", r.RemoteAddr, "has_authorization", r.Header.Get("Authorization") != "")
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSONError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		ws.ServeHTTP(w, r)
+	})
+}
+
+func (a *app) serveWebSocket(conn *websocket.Conn, sig otlpSignal, es *endpointStats) {
+	r := conn.Request()
+	slog.Info("websocket connected", "path", r.URL.Path, "remote", r.RemoteAddr)
+	defer func() { _ = conn.Close() }()
+
+	var messages int
+	defer func() {
+		slog.Info("websocket disconnected", "path", r.URL.Path, "remote", r.RemoteAddr, "messages", messages)
+	}()
+
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(conn, &data); err != nil {
+			if !errors.Is(err, io.EOF) {
+				slog.Warn("websocket receive failed", "path", r.URL.Path, "remote", r.RemoteAddr, "error", err)
+			}
+			return
+		}
+		messages++
+		if err := a.handleWebSocketMessage(conn, sig, es, data); err != nil {
+			slog.Warn("websocket send failed", "path", r.URL.Path, "remote", r.RemoteAddr, "error", err)
+			return
+		}
+	}
+}
+
+func (a *app) handleWebSocketMessage(conn *websocket.Conn, sig otlpSignal, es *endpointStats, data []byte) error {
+	r := conn.Request()
+	start := time.Now()
+	tenantName, tn := a.tenants.get(r.Header.Get(tenantHeader))
+	tes := tn.stats.signal(sig)
+	a.stats.begin()
+	defer a.stats.done()
+	tn.stats.begin()
+	defer tn.stats.done()
+
+	es.record(len(data))
+	tes.record(len(data))
+	a.stats.userAgents.add(r.UserAgent())
+	tn.stats.userAgents.add(r.UserAgent())
+	requestID := newRequestID(a.cfg.fastRequestIDs)
+	entry := &recentRequest{
+		Time:        start,
+		RequestID:   requestID,
+		Path:        r.URL.Path,
+		Signal:      sig.String(),
+		Tenant:      tenantName,
+		SizeBytes:   len(data),
+		ContentType: contentTypeProtobuf,
+		Status:      http.StatusOK,
+	}
+	args := []any{"method", "ws", "path", r.URL.Path, "size_bytes", len(data)}
+
+	var resp []byte
+	msg, err := decodeRequest(sig, contentTypeProtobuf, data)
+	if err == nil && a.cfg.strict {
+		err = validateRequest(msg, true)
+	}
+	if err == nil {
+		a.stats.observe(msg)
+		tn.stats.observe(msg)
+		n := countMessage(msg)
+		entry.Items = &n
+		args = append(args, sig.itemsKey(), n)
+		resp, err = proto.Marshal(newExportResponse(sig))
+	}
+	if err != nil {
+		entry.Status = http.StatusBadRequest
+		args = append(args, "error", err)
+	}
+
+	elapsed := time.Since(start)
+	es.recordDuration(elapsed)
+	tes.recordDuration(elapsed)
+	a.recent.add(entry)
+	tn.recent.add(entry)
+	a.events.publish(entry)
+	args = append(args, "remote", r.RemoteAddr, "request_id", requestID, "status", entry.Status, "duration_ms", durationMs(elapsed))
+	slog.Info("request", args...)
+
+	if err != nil {
+		return websocket.JSON.Send(conn, map[string]string{"error": err.Error()})
+	}
+	return websocket.Message.Send(conn, resp)
+}